@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// DeleteAccount 删除当前用户的账号及其容器、网络、数据卷和快照
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	user, unlock, err := h.lockUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	defer unlock()
+
+	if err := h.deleteAccount(c.Request.Context(), user); err != nil {
+		respondError(c, accountError(err))
+		return
+	}
+	respondData(c, http.StatusOK, gin.H{"userID": user.ID})
+}
+
+// AdminDeleteUser 管理员删除指定用户的账号
+func (h *Handler) AdminDeleteUser(c *gin.Context) {
+	userID := c.Param("id")
+	unlock := h.locks.lock(userID)
+	defer unlock()
+
+	user, err := h.DB.GetUser(userID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusNotFound, "User not found"))
+		return
+	}
+
+	if err := h.deleteAccount(c.Request.Context(), user); err != nil {
+		respondError(c, accountError(err))
+		return
+	}
+	respondData(c, http.StatusOK, gin.H{"userID": user.ID})
+}
+
+// accountError 将删除账号的错误转换为平台错误，Docker 和数据库的错误为内部错误
+func accountError(err error) error {
+	var httpErr *httpError
+	switch {
+	case errors.Is(err, errSetupBusy):
+		return busyError(err)
+	case errors.As(err, &httpErr):
+		return err
+	default:
+		return internalError(err.Error())
+	}
+}
+
+// deleteAccount 依次删除用户的容器、网络、数据卷、快照和用户记录，调用方需持有用户锁。
+// 中途失败时保存已完成的清理结果，便于重试。搭建操作执行中时返回 errSetupBusy
+func (h *Handler) deleteAccount(ctx context.Context, user *models.User) error {
+	release, err := h.busy.acquire(user.ID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		return err
+	}
+	exists, err := dm.ContainerExists(ctx, user.ContainerID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err := dm.RemoveContainer(ctx, user.ContainerID); err != nil {
+			return err
+		}
+	}
+	user.ContainerID = ""
+	h.releasePort(user)
+
+	if user.Network != "" {
+		if err := h.removeUserNetwork(ctx, dm, user.Network); err != nil {
+			_ = h.DB.SaveUser(user)
+			return err
+		}
+		user.Network = ""
+	}
+	if user.Volume != "" {
+		if err := dm.RemoveVolume(ctx, user.Volume); err != nil {
+			_ = h.DB.SaveUser(user)
+			return err
+		}
+		user.Volume = ""
+	}
+	if err := h.deleteUserSnapshots(ctx, user.ID); err != nil {
+		_ = h.DB.SaveUser(user)
+		return err
+	}
+
+	return h.DB.DeleteUser(user.ID)
+}
+
+// ResetVolume 清空当前用户的数据卷，需先删除容器
+func (h *Handler) ResetVolume(c *gin.Context) {
+	user, unlock, err := h.lockUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	defer unlock()
+
+	ctx := c.Request.Context()
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	exists, err := dm.ContainerExists(ctx, user.ContainerID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to inspect existing container"))
+		return
+	}
+	if exists {
+		respondError(c, newAPIError(http.StatusConflict, "Remove the container before resetting its volume"))
+		return
+	}
+
+	if user.Volume == "" {
+		respondData(c, http.StatusOK, gin.H{"volume": ""})
+		return
+	}
+	if err := dm.RemoveVolume(ctx, user.Volume); err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	if err := dm.EnsureVolume(ctx, user.Volume, map[string]string{docker.LabelOwner: user.ID}); err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	respondData(c, http.StatusOK, gin.H{"volume": user.Volume})
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RequireAdmin 仅允许管理员访问的中间件，需放在 JWTMiddleware 之后
+func (h *Handler) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := h.getUserFromContext(c)
+		if err != nil {
+			handleHttpError(c, err)
+			c.Abort()
+			return
+		}
+		if !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (h *Handler) ListImages(c *gin.Context) {
+	images, err := h.DB.ListImages()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list images"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"images": images})
+}
+
+func (h *Handler) SaveImage(c *gin.Context) {
+	var image models.Image
+	if err := c.ShouldBindJSON(&image); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if image.ID == "" || image.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image id and name are required"})
+		return
+	}
+	if image.Resources.CPUs < 0 || image.Resources.MemoryMB < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid resource profile"})
+		return
+	}
+
+	if err := h.DB.SaveImage(&image); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"image": image})
+}
+
+// DeleteImage 从镜像目录删除镜像，仍有实验使用该镜像时返回 409 和这些实验的ID
+func (h *Handler) DeleteImage(c *gin.Context) {
+	imageID := c.Param("id")
+	labs, err := h.DB.ListLabs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list labs"})
+		return
+	}
+	var inUse []string
+	for _, lab := range labs {
+		if lab.ImageID == imageID {
+			inUse = append(inUse, lab.ID)
+		}
+	}
+	if len(inUse) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Image is used by labs", "labs": inUse})
+		return
+	}
+
+	if err := h.DB.DeleteImage(imageID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": "Successfully Deleted Image"})
+}
+
+// PullImage 在所有 Docker 主机上拉取并校验镜像目录中的镜像，以 NDJSON 流式返回拉取进度
+func (h *Handler) PullImage(c *gin.Context) {
+	image, err := h.DB.GetImage(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	ctx := c.Request.Context()
+
+	for _, host := range h.Docker.Hosts() {
+		err := host.PullImage(ctx, image.Reference(), func(p docker.PullProgress) {
+			_ = encoder.Encode(p)
+			c.Writer.Flush()
+		})
+		if err == nil {
+			err = host.VerifyImage(ctx, image.Reference(), image.Digest)
+		}
+		// 响应头已发送，每台主机的结果写在其进度之后
+		if err != nil {
+			_ = encoder.Encode(gin.H{"status": "failed", "host": host.Name(), "error": err.Error()})
+		} else {
+			_ = encoder.Encode(gin.H{"status": "verified", "host": host.Name(), "image": image.Reference()})
+		}
+		c.Writer.Flush()
+	}
+}
+
+func (h *Handler) ListLabs(c *gin.Context) {
+	labs, err := h.DB.ListLabs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list labs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"labs": labs})
+}
+
+func (h *Handler) SaveLab(c *gin.Context) {
+	var lab models.Lab
+	if err := c.ShouldBindJSON(&lab); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if lab.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lab id is required"})
+		return
+	}
+	if lab.ImageID != "" {
+		if _, err := h.DB.GetImage(lab.ImageID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Image not found in catalog"})
+			return
+		}
+	}
+
+	if err := h.DB.SaveLab(&lab); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save lab"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lab": lab})
+}
+
+// DeleteLab 删除实验，仍有学生加入或排期时段引用该实验时返回 409 和引用方的ID
+func (h *Handler) DeleteLab(c *gin.Context) {
+	labID := c.Param("id")
+	users, err := h.DB.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+	sessions, err := h.DB.ListLabSessions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list lab sessions"})
+		return
+	}
+	var userIDs, sessionIDs []string
+	for _, user := range users {
+		if user.LabID == labID {
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+	for _, session := range sessions {
+		if session.LabID == labID {
+			sessionIDs = append(sessionIDs, session.ID)
+		}
+	}
+	if len(userIDs) > 0 || len(sessionIDs) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Lab is in use", "users": userIDs, "sessions": sessionIDs})
+		return
+	}
+
+	if err := h.DB.DeleteLab(labID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete lab"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": "Successfully Deleted Lab"})
+}
+
+func (h *Handler) ListLabSessions(c *gin.Context) {
+	sessions, err := h.DB.ListLabSessions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list lab sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// SaveLabSession 排期实验课时段，容器池会在开始前按 poolSize 预热该实验的镜像
+func (h *Handler) SaveLabSession(c *gin.Context) {
+	var session models.LabSession
+	if err := c.ShouldBindJSON(&session); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if session.ID == "" || !session.EndsAt.After(session.StartsAt) || session.PoolSize < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lab session"})
+		return
+	}
+	if _, err := h.DB.GetLab(session.LabID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lab not found"})
+		return
+	}
+
+	if err := h.DB.SaveLabSession(&session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save lab session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session": session})
+}
+
+func (h *Handler) DeleteLabSession(c *gin.Context) {
+	if err := h.DB.DeleteLabSession(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete lab session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": "Successfully Deleted Lab Session"})
+}
+
+// PoolStatus 返回容器池中各镜像的空闲容器数量
+func (h *Handler) PoolStatus(c *gin.Context) {
+	if h.Pool == nil {
+		c.JSON(http.StatusOK, gin.H{"pool": gin.H{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pool": h.Pool.Status()})
+}
+
+// AssignUserLab 将学生分配到实验，之后创建的容器使用该实验的镜像
+func (h *Handler) AssignUserLab(c *gin.Context) {
+	var req struct {
+		LabID string `json:"labID"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.LabID != "" {
+		if _, err := h.DB.GetLab(req.LabID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lab not found"})
+			return
+		}
+	}
+
+	userID := c.Param("id")
+	unlock := h.locks.lock(userID)
+	defer unlock()
+
+	user, err := h.DB.GetUser(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	user.LabID = req.LabID
+	if err := h.DB.SaveUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": "Successfully Assigned Lab", "labID": user.LabID})
+}
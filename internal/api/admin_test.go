@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/database"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAdminRouter(handler *Handler, userID string) *gin.Engine {
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", userID)
+	})
+	admin := router.Group("/admin")
+	admin.Use(handler.RequireAdmin())
+	{
+		admin.POST("/images", handler.SaveImage)
+		admin.DELETE("/images/:id", handler.DeleteImage)
+		admin.POST("/labs", handler.SaveLab)
+		admin.DELETE("/labs/:id", handler.DeleteLab)
+		admin.PUT("/users/:id/lab", handler.AssignUserLab)
+	}
+	return router
+}
+
+func TestRequireAdminRejectsStudent(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "student", Role: models.RoleStudent})
+	router := setupAdminRouter(handler, "student")
+
+	imageJSON, _ := json.Marshal(models.Image{ID: "chain-v1", Name: "chain-proxy", Tag: "v1"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/admin/images", bytes.NewBuffer(imageJSON))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, mockDB.Images)
+}
+
+func TestAssignLabWithCatalogImage(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "admin", Role: models.RoleAdmin})
+	mockDB.SaveUser(&models.User{ID: "student", Role: models.RoleStudent})
+	router := setupAdminRouter(handler, "admin")
+
+	// 实验引用的镜像必须存在于目录中
+	labJSON, _ := json.Marshal(models.Lab{ID: "lab1", ImageID: "chain-v1"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/admin/labs", bytes.NewBuffer(labJSON))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	imageJSON, _ := json.Marshal(models.Image{ID: "chain-v1", Name: "chain-proxy", Tag: "v1"})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/admin/images", bytes.NewBuffer(imageJSON))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/admin/labs", bytes.NewBuffer(labJSON))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/admin/users/student/lab", bytes.NewBufferString(`{"labID":"lab1"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	image, err := handler.resolveImage(mockDB.Users["student"])
+	assert.NoError(t, err)
+	assert.Equal(t, "chain-proxy:v1", image.Reference())
+}
+
+func TestDeleteImageInUse(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "admin", Role: models.RoleAdmin})
+	mockDB.SaveImage(&models.Image{ID: "chain-v1", Name: "chain-proxy", Tag: "v1"})
+	mockDB.SaveLab(&models.Lab{ID: "lab1", ImageID: "chain-v1"})
+	router := setupAdminRouter(handler, "admin")
+
+	// 仍被实验引用的镜像不能删除
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/admin/images/chain-v1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "lab1")
+	assert.Contains(t, mockDB.Images, "chain-v1")
+
+	mockDB.SaveLab(&models.Lab{ID: "lab1"})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/admin/images/chain-v1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, mockDB.Images, "chain-v1")
+}
+
+func TestDeleteLabInUse(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "admin", Role: models.RoleAdmin})
+	mockDB.SaveUser(&models.User{ID: "student", LabID: "lab1"})
+	mockDB.SaveLab(&models.Lab{ID: "lab1"})
+	mockDB.SaveLabSession(&models.LabSession{ID: "s1", LabID: "lab1"})
+	router := setupAdminRouter(handler, "admin")
+
+	deleteLab := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/admin/labs/lab1", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 仍有学生加入或时段排期的实验不能删除
+	w := deleteLab()
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "student")
+	assert.Contains(t, w.Body.String(), "s1")
+
+	mockDB.SaveUser(&models.User{ID: "student"})
+	assert.NoError(t, mockDB.DeleteLabSession("s1"))
+	assert.Equal(t, http.StatusOK, deleteLab().Code)
+	assert.NotContains(t, mockDB.Labs, "lab1")
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// limitedBody 读取超过上限时返回错误的响应体，用于限制未声明长度的流式响应
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// 多读一个字节，以区分恰好达到上限和超过上限
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, docker.ErrChainResponseTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// parseChainPath 解析 exec 命令中的 chain-proxy 路径，返回解码后的路径和其中的查询参数。
+// 策略检查、编译判断、超时和实际请求都使用解码后的路径，编码的 "/"（如 "..%2F"）直接拒绝
+func parseChainPath(raw string) (string, url.Values, error) {
+	u, err := url.Parse(raw)
+	// RawPath 不为空说明路径中有编码的 "/" 等非规范转义
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" || u.Fragment != "" || u.RawPath != "" {
+		return "", nil, invalidChainPath(raw)
+	}
+	if err := checkChainPath(u.Path); err != nil {
+		return "", nil, err
+	}
+	return u.Path, u.Query(), nil
+}
+
+// checkChainPath 要求解码后的路径以 "/" 开头，不含 "." 和 ".." 段，也不含会被再次解码的字符
+func checkChainPath(p string) error {
+	if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "%?#\\") {
+		return invalidChainPath(p)
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "." || segment == ".." {
+			return invalidChainPath(p)
+		}
+	}
+	return nil
+}
+
+func invalidChainPath(p string) error {
+	return &models.ExecPolicyError{Code: models.ExecErrPathNotAllowed, Message: fmt.Sprintf("invalid path %q", p)}
+}
+
+// chainContext 返回带 path 对应超时的 ctx，客户端断开时随请求 ctx 一起取消
+func (h *Handler) chainContext(ctx context.Context, path string) (context.Context, context.CancelFunc) {
+	timeout, ok := h.cfg().ChainTimeouts[path]
+	if !ok {
+		timeout = h.cfg().ChainTimeout
+	}
+	if timeout <= 0 {
+		timeout = docker.DefaultChainTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ChainProxy 将 /api/chain/*path 透传到当前用户容器内的 chain-proxy。路径和方法受 exec 策略约束，
+// 只转发 ExecHeaders 中的请求头，响应按原状态码流式返回，大小受 ChainMaxResponseSize 限制。平台自身的错误以 {data, error} 格式返回。
+// /setup/ 下的搭建路径与搭建接口共用用户的搭建标记
+func (h *Handler) ChainProxy(c *gin.Context) {
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if user.ContainerID == "" {
+		respondError(c, noContainerError())
+		return
+	}
+
+	// 策略需要检查请求体，因此先完整读取（请求体很小）
+	var body []byte
+	if c.Request.Body != nil {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.cfg().ChainMaxRequestSize)
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			respondError(c, &apiError{Status: http.StatusRequestEntityTooLarge, Code: ErrCodeBadRequest, Message: "Request body too large"})
+			return
+		}
+	}
+
+	// gin 按解码后的路径匹配，请求中有编码的 "/" 时 RawPath 不为空
+	path := c.Param("path")
+	if c.Request.URL.RawPath != "" {
+		err = invalidChainPath(c.Request.URL.RawPath)
+	} else {
+		err = checkChainPath(path)
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	policy, err := h.execPolicy(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := policy.Check(c.Request.Method, path, string(body)); err != nil {
+		respondError(c, err)
+		return
+	}
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if isSetupPath(path) {
+		release, err := h.busy.acquire(user.ID)
+		if err != nil {
+			respondError(c, busyError(err))
+			return
+		}
+		defer release()
+	}
+	if isBuildPath(path) {
+		if !h.takeQuota(c, user, QuotaBuildChain) {
+			return
+		}
+		defer h.refundOnFailure(c, user, QuotaBuildChain)
+	}
+
+	ctx, cancel := h.chainContext(c.Request.Context(), path)
+	defer cancel()
+	c.Request = c.Request.WithContext(ctx)
+
+	target, err := dm.ChainTarget(ctx, user.ContainerID)
+	if err != nil {
+		respondError(c, chainError(err))
+		return
+	}
+
+	maxSize := h.cfg().ChainMaxResponseSize
+	allowedHeaders := h.cfg().ExecHeaders
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.Out.URL.Path = path
+			r.Out.URL.RawPath = ""
+			// 与 exec 使用同一请求头允许列表，平台的登录凭据等其他请求头不转发给学生容器
+			r.Out.Header = models.FilterHeaders(allowedHeaders, r.Out.Header)
+		},
+		Transport: dm.ChainTransport(),
+		// 立即刷新，支持 chain-proxy 的流式响应
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.ContentLength > maxSize {
+				return docker.ErrChainResponseTooLarge
+			}
+			resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: maxSize}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			respondError(c, chainError(err))
+		},
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
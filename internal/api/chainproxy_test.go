@@ -0,0 +1,346 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/config"
+	"github.com/cynic-1/blockchain-teaching-system/internal/database"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupChainProxy 启动平台服务，student 的容器 c1 映射到 chain 服务。
+// ReverseProxy 需要 CloseNotifier，因此使用真实的 HTTP 服务而非 ResponseRecorder
+func setupChainProxy(t *testing.T, chain http.Handler) (*httptest.Server, *Handler) {
+	chainSrv := httptest.NewServer(chain)
+	t.Cleanup(chainSrv.Close)
+	_, chainPort, _ := net.SplitHostPort(chainSrv.Listener.Addr().String())
+
+	// 模拟 Docker 端点，容器 c1 的 8080 端口发布到 chain 服务的端口
+	dockerSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/c1/json") {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: "c1", State: &types.ContainerState{Running: true}},
+			NetworkSettings: &types.NetworkSettings{NetworkSettingsBase: types.NetworkSettingsBase{
+				Ports: nat.PortMap{"8080/tcp": []nat.PortBinding{{HostPort: chainPort}}},
+			}},
+		})
+	}))
+	t.Cleanup(dockerSrv.Close)
+
+	dm, err := docker.NewMultiHostManager("1.41", []docker.HostConfig{
+		{Name: "lab-a", Endpoint: "tcp://" + dockerSrv.Listener.Addr().String(), ProxyAddress: "127.0.0.1"},
+	})
+	assert.NoError(t, err)
+
+	cfg := config.NewConfig()
+	cfg.ChainMaxResponseSize = 64
+	handler := &Handler{DB: database.NewMockDatabase(), Docker: dm, Config: cfg}
+	handler.DB.SaveUser(&models.User{ID: "student", ContainerID: "c1"})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "student")
+	})
+	router.Any("/api/chain/*path", handler.ChainProxy)
+	router.POST("/api/container/exec", handler.Exec)
+	router.POST("/api/cluster/stop", handler.StopCluster)
+	router.POST("/api/build-blockchain", handler.BuildBlockchainBinary)
+	router.GET("/api/jobs/:id", handler.GetJob)
+	router.GET("/api/jobs/:id/stream", handler.StreamJob)
+	router.POST("/api/pipelines/:name/run", handler.RunPipeline)
+	router.GET("/api/pipeline-runs/:id", handler.GetPipelineRun)
+	router.POST("/api/pipeline-runs/:id/resume", handler.ResumePipelineRun)
+	platform := httptest.NewServer(router)
+	t.Cleanup(platform.Close)
+	return platform, handler
+}
+
+func doChainRequest(t *testing.T, platform *httptest.Server, method, path, body string, header http.Header) (int, http.Header, string) {
+	req, _ := http.NewRequest(method, platform.URL+path, strings.NewReader(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, nil, ""
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header, string(data)
+}
+
+func TestChainProxyForwardsAllowedRequests(t *testing.T) {
+	platform, _ := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen-Auth", r.Header.Get("Authorization"))
+		w.Header().Set("X-Seen-Cookie", r.Header.Get("Cookie"))
+		w.Header().Set("X-Seen-Custom", r.Header.Get("X-Custom"))
+		w.Header().Set("X-Seen-Accept", r.Header.Get("Accept"))
+		if r.URL.Path == "/proxy/-1/blocks/height/7" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+
+	code, header, body := doChainRequest(t, platform, "GET", "/api/chain/proxy/-1/consensus?verbose=1", "",
+		http.Header{"Authorization": {"Bearer platform-token"}, "Cookie": {"session=1"},
+			"X-Custom": {"x"}, "Accept": {"application/json"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "GET /proxy/-1/consensus?verbose=1", body)
+	// 只转发 ExecHeaders 中的请求头
+	assert.Empty(t, header.Get("X-Seen-Auth"))
+	assert.Empty(t, header.Get("X-Seen-Cookie"))
+	assert.Empty(t, header.Get("X-Seen-Custom"))
+	assert.Equal(t, "application/json", header.Get("X-Seen-Accept"))
+
+	// 上游状态码原样返回
+	code, _, _ = doChainRequest(t, platform, "GET", "/api/chain/proxy/-1/blocks/height/7", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _, body = doChainRequest(t, platform, "POST", "/api/chain/setup/new/factory",
+		`{"nodeCount":4,"stakeQuota":9999,"windowSize":4}`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "POST /setup/new/factory?", body)
+}
+
+func TestExecResponseEnvelope(t *testing.T) {
+	platform, _ := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/setup/genesis/addrs":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"addrs":["a","b"]}`))
+		case "/setup/genesis/random":
+			http.Error(w, `{"reason":"no addrs"}`, http.StatusBadRequest)
+		case "/setup/genesis/template":
+			http.Error(w, "genesis failed", http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte("ok\n"))
+		}
+	}))
+
+	type response struct {
+		Data  *chainResult `json:"data"`
+		Error *apiError    `json:"error"`
+	}
+	exec := func(path string) (int, response) {
+		code, _, body := doChainRequest(t, platform, "POST", "/api/container/exec", `{"cmd":["mis","`+path+`"]}`, nil)
+		var resp response
+		assert.NoError(t, json.Unmarshal([]byte(body), &resp), body)
+		return code, resp
+	}
+
+	code, resp := exec("/setup/genesis/addrs")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, map[string]interface{}{"addrs": []interface{}{"a", "b"}}, resp.Data.Result)
+
+	code, resp = exec("/setup/reset/workdir")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp.Data.Result)
+
+	// 上游 4xx 保留状态码，响应体解析后放在 detail 中
+	code, resp = exec("/setup/genesis/random")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Nil(t, resp.Data)
+	assert.Equal(t, ErrCodeChainRejected, resp.Error.Code)
+	assert.Equal(t, map[string]interface{}{"reason": "no addrs"}, resp.Error.Detail)
+
+	code, resp = exec("/setup/genesis/template")
+	assert.Equal(t, http.StatusBadGateway, code)
+	assert.Equal(t, ErrCodeChainFailed, resp.Error.Code)
+	assert.Equal(t, http.StatusInternalServerError, resp.Error.UpstreamStatus)
+	assert.Equal(t, "genesis failed", resp.Error.Detail)
+
+	code, resp = exec("/admin/shutdown")
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, models.ExecErrPathNotAllowed, resp.Error.Code)
+}
+
+func TestExecMethodQueryAndHeaders(t *testing.T) {
+	platform, _ := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"request": r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery,
+			"accept":  r.Header.Get("Accept"),
+		})
+	}))
+
+	code, _, body := doChainRequest(t, platform, "POST", "/api/container/exec",
+		`{"cmd":["mis","/proxy/-1/blocks/height/7"],"method":"get","query":{"full":"true"},"headers":{"accept":"application/json"}}`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"request":"GET /proxy/-1/blocks/height/7?full=true"`)
+	assert.Contains(t, body, `"accept":"application/json"`)
+
+	tests := []struct {
+		body string
+		code int
+		want string
+	}{
+		// 状态查询只允许 GET，未指定方法时为 POST
+		{`{"cmd":["mis","/proxy/-1/consensus"]}`, http.StatusForbidden, models.ExecErrMethodNotAllowed},
+		{`{"cmd":["mis","/setup/cluster/start"],"method":"GET"}`, http.StatusForbidden, models.ExecErrMethodNotAllowed},
+		{`{"cmd":["mis","/proxy/-1/txpool","{}"],"method":"GET"}`, http.StatusBadRequest, models.ExecErrInvalidBody},
+		{`{"cmd":["mis","/proxy/-1/txpool"],"method":"GET","headers":{"Host":"admin"}}`, http.StatusForbidden, models.ExecErrHeaderNotAllowed},
+	}
+	for _, tt := range tests {
+		code, _, body := doChainRequest(t, platform, "POST", "/api/container/exec", tt.body, nil)
+		assert.Equal(t, tt.code, code, tt.body)
+		assert.Contains(t, body, `"code":"`+tt.want+`"`, tt.body)
+	}
+}
+
+func TestChainProxyRejectsDisallowedRequests(t *testing.T) {
+	platform, _ := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}))
+
+	tests := []struct {
+		method, path, body string
+		code               int
+	}{
+		{"GET", "/api/chain/admin/shutdown", "", http.StatusForbidden},
+		{"DELETE", "/api/chain/proxy/-1/txpool", "", http.StatusForbidden},
+		{"GET", "/api/chain/setup/new/factory", "", http.StatusForbidden},
+		{"POST", "/api/chain/setup/new/factory", `{"nodeCount":"4"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		code, _, _ := doChainRequest(t, platform, tt.method, tt.path, tt.body, nil)
+		assert.Equal(t, tt.code, code, tt.method+" "+tt.path)
+	}
+}
+
+func TestExecRejectsEncodedTraversal(t *testing.T) {
+	platform, _ := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}))
+
+	// "*" 能匹配编码后的 "..%2F.."，解码后才会变成路径穿越，因此按解码后的路径检查并直接拒绝
+	for _, path := range []string{
+		"/proxy/..%2F..%2Fadmin/consensus",
+		"/proxy/%2E%2E/consensus",
+		"/proxy/-1%252F/consensus",
+		"//admin/proxy/-1/consensus",
+	} {
+		code, _, body := doChainRequest(t, platform, "POST", "/api/container/exec",
+			`{"cmd":["mis","`+path+`"],"method":"GET"}`, nil)
+		assert.Equal(t, http.StatusForbidden, code, path)
+		assert.Contains(t, body, `"code":"`+models.ExecErrPathNotAllowed+`"`, path)
+
+		code, _, _ = doChainRequest(t, platform, "GET", "/api/chain"+path, "", nil)
+		assert.NotEqual(t, http.StatusOK, code, path)
+	}
+}
+
+func TestParseChainPath(t *testing.T) {
+	path, query, err := parseChainPath("/proxy/-1/blocks/height/7?full=true")
+	assert.NoError(t, err)
+	assert.Equal(t, "/proxy/-1/blocks/height/7", path)
+	assert.Equal(t, "true", query.Get("full"))
+
+	for _, raw := range []string{"proxy/-1/consensus", "/setup/./cluster/start", "/setup/%2e%2e/admin", "/setup%2Fcluster/start", "http://evil/setup"} {
+		_, _, err := parseChainPath(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestChainProxyResponseSizeCap(t *testing.T) {
+	platform, _ := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 128))
+	}))
+
+	code, _, body := doChainRequest(t, platform, "GET", "/api/chain/proxy/-1/txpool", "", nil)
+	assert.Equal(t, http.StatusBadGateway, code)
+	assert.Contains(t, body, `"code":"`+ErrCodeChainTooLarge+`"`)
+}
+
+func TestLimitedBody(t *testing.T) {
+	body := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("abcd")), remaining: 4}
+	buf := new(bytes.Buffer)
+	_, err := buf.ReadFrom(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", buf.String())
+
+	body = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("abcde")), remaining: 4}
+	buf.Reset()
+	_, err = buf.ReadFrom(body)
+	assert.ErrorIs(t, err, docker.ErrChainResponseTooLarge)
+	assert.Equal(t, "abcd", buf.String())
+}
+
+func TestChainRequestTimeouts(t *testing.T) {
+	platform, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	handler.Config.ChainTimeout = 50 * time.Millisecond
+	handler.Config.ChainTimeouts = map[string]time.Duration{"/setup/cluster/stop": 80 * time.Millisecond}
+
+	start := time.Now()
+	code, _, body := doChainRequest(t, platform, "POST", "/api/cluster/stop", "", nil)
+	assert.Equal(t, http.StatusGatewayTimeout, code)
+	assert.Contains(t, body, `"step":"cluster-stop"`)
+	assert.Contains(t, body, `"code":"`+ErrCodeChainTimeout+`"`)
+
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/container/exec", `{"cmd":["mis","/setup/genesis/addrs"]}`, nil)
+	assert.Equal(t, http.StatusGatewayTimeout, code)
+
+	code, _, _ = doChainRequest(t, platform, "GET", "/api/chain/proxy/-1/consensus", "", nil)
+	assert.Equal(t, http.StatusGatewayTimeout, code)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestChainRequestOnRemovedHost(t *testing.T) {
+	platform, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL.Path)
+	}))
+	// 用户记录的主机已从配置中移除，不在默认主机上操作同名容器
+	handler.DB.SaveUser(&models.User{ID: "student", ContainerID: "c1", DockerHost: "lab-removed"})
+
+	code, _, body := doChainRequest(t, platform, "POST", "/api/container/exec", `{"cmd":["mis","/setup/genesis/addrs"]}`, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "lab-removed")
+	code, _, _ = doChainRequest(t, platform, "GET", "/api/chain/proxy/-1/consensus", "", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, _, body = doChainRequest(t, platform, "POST", "/api/cluster/stop", "", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, `"step":"cluster-stop"`)
+}
+
+func TestChainContext(t *testing.T) {
+	handler := &Handler{Config: config.NewConfig()}
+
+	ctx, cancel := handler.chainContext(context.Background(), "/setup/build/chain")
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), deadline, time.Second)
+
+	ctx, cancel = handler.chainContext(context.Background(), "/proxy/-1/txpool")
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(handler.Config.ChainTimeout), deadline, time.Second)
+
+	// 客户端断开时请求随之取消
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel = handler.chainContext(parent, "/setup/build/chain")
+	defer cancel()
+	cancelParent()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Equal(t, http.StatusBadGateway, chainError(ctx.Err()).Status)
+}
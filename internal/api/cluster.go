@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// setupRequest 不带请求体的搭建步骤请求
+func setupRequest(path string) docker.ChainRequest {
+	return docker.ChainRequest{Method: "POST", Path: path}
+}
+
+// setupError 为错误标记出错的搭建步骤
+func setupError(step string, err error) *apiError {
+	apiErr := *toAPIError(err)
+	apiErr.Step = step
+	return &apiErr
+}
+
+// busyError 用户有执行中的搭建操作时返回的冲突错误
+func busyError(err error) *apiError {
+	return &apiError{Status: http.StatusConflict, Code: ErrCodeConflict, Message: err.Error()}
+}
+
+// isSetupPath 判断 chain-proxy 路径是否为搭建步骤。通过 exec 或透传代理调用搭建步骤时，
+// 与搭建接口一样占用用户的搭建标记
+func isSetupPath(path string) bool {
+	return strings.HasPrefix(path, "/setup/")
+}
+
+// runSetupStep 对当前用户的容器执行搭建步骤，成功时 data 为 {"step", "statusCode", "result"}，
+// 失败时 error 带有 step。请求带 ?async=true 时创建后台任务并立即返回 202 和任务ID，输出通过 /jobs/:id 查询，
+// 任务失败时归还编译配额。
+// 用户已有执行中的搭建任务、流水线或同步搭建步骤时返回 409
+func (h *Handler) runSetupStep(c *gin.Context, step string, req docker.ChainRequest) {
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, setupError(step, err))
+		return
+	}
+	if user.ContainerID == "" {
+		respondError(c, setupError(step, noContainerError()))
+		return
+	}
+	// 同步请求和后台任务都先检查 exec 策略，实验可以禁用某些搭建步骤
+	if err := h.checkExecPolicy(user, req); err != nil {
+		respondError(c, setupError(step, err))
+		return
+	}
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, setupError(step, err))
+		return
+	}
+
+	if c.Query("async") == "true" && h.Jobs != nil {
+		// 202 不会触发失败归还，编译配额在任务失败时归还
+		refund := h.deferRefund(c, user, QuotaBuildChain)
+		job, err := h.Jobs.Start(dm, user, step, req, refund)
+		if err != nil && refund != nil {
+			refund()
+		}
+		if errors.Is(err, errSetupBusy) {
+			respondError(c, setupError(step, busyError(err)))
+			return
+		}
+		if err != nil {
+			respondError(c, setupError(step, &apiError{Status: http.StatusInternalServerError, Code: ErrCodeInternal, Message: "Failed to create job"}))
+			return
+		}
+		respondData(c, http.StatusAccepted, gin.H{"step": step, "jobID": job.ID})
+		return
+	}
+
+	release, err := h.busy.acquire(user.ID)
+	if err != nil {
+		respondError(c, setupError(step, busyError(err)))
+		return
+	}
+	defer release()
+	ctx, cancel := h.chainContext(c.Request.Context(), req.Path)
+	defer cancel()
+	resp, err := dm.DoChainRequest(ctx, user.ContainerID, req)
+	if err == nil {
+		err = resp.Err()
+	}
+	if err != nil {
+		respondError(c, setupError(step, chainError(err)))
+		return
+	}
+	respondData(c, http.StatusOK, chainResult{Step: step, StatusCode: resp.StatusCode, Result: resp.Value()})
+}
+
+// CreateClusterFactory 创建本地集群工厂
+func (h *Handler) CreateClusterFactory(c *gin.Context) {
+	var req struct {
+		NodeCount  int `json:"nodeCount"`
+		StakeQuota int `json:"stakeQuota"`
+		WindowSize int `json:"windowSize"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, setupError("factory", &apiError{Status: http.StatusBadRequest, Code: ErrCodeBadRequest, Message: err.Error()}))
+		return
+	}
+	if req.NodeCount < 1 || req.NodeCount > h.cfg().MaxClusterNodes {
+		respondError(c, setupError("factory", &apiError{Status: http.StatusBadRequest, Code: ErrCodeBadRequest,
+			Message: "nodeCount must be between 1 and " + strconv.Itoa(h.cfg().MaxClusterNodes)}))
+		return
+	}
+	if req.StakeQuota <= 0 || req.WindowSize <= 0 {
+		respondError(c, setupError("factory", &apiError{Status: http.StatusBadRequest, Code: ErrCodeBadRequest,
+			Message: "stakeQuota and windowSize must be positive"}))
+		return
+	}
+
+	factory := setupRequest(docker.SetupFactoryPath)
+	factory.Body = docker.ClusterFactoryBody(req.NodeCount, req.StakeQuota, req.WindowSize)
+	h.runSetupStep(c, "factory", factory)
+}
+
+func (h *Handler) ResetWorkingDirectory(c *gin.Context) {
+	h.runSetupStep(c, "reset-workdir", setupRequest(docker.SetupResetPath))
+}
+
+func (h *Handler) MakeLocalAddresses(c *gin.Context) {
+	h.runSetupStep(c, "genesis-addrs", setupRequest(docker.SetupAddrsPath))
+}
+
+func (h *Handler) MakeValidatorKeysAndStakeQuotas(c *gin.Context) {
+	h.runSetupStep(c, "genesis-random", setupRequest(docker.SetupKeysPath))
+}
+
+func (h *Handler) WriteGenesisFiles(c *gin.Context) {
+	h.runSetupStep(c, "genesis-template", setupRequest(docker.SetupGenesisPath))
+}
+
+func (h *Handler) CreateCluster(c *gin.Context) {
+	h.runSetupStep(c, "new-cluster", setupRequest(docker.SetupClusterPath))
+}
+
+func (h *Handler) BuildBlockchainBinary(c *gin.Context) {
+	h.runSetupStep(c, "build-chain", setupRequest(docker.SetupBuildPath))
+}
+
+func (h *Handler) StartCluster(c *gin.Context) {
+	h.runSetupStep(c, "cluster-start", setupRequest(docker.ClusterStartPath))
+}
+
+func (h *Handler) StopCluster(c *gin.Context) {
+	h.runSetupStep(c, "cluster-stop", setupRequest(docker.ClusterStopPath))
+}
+
+// ClusterLiveness 通过查询共识状态判断集群是否存活。集群未响应时仍返回 200，
+// data 为 {"alive": false, "reason": 错误}
+func (h *Handler) ClusterLiveness(c *gin.Context) {
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, setupError("liveness", err))
+		return
+	}
+	if user.ContainerID == "" {
+		respondError(c, setupError("liveness", noContainerError()))
+		return
+	}
+	req := docker.ChainRequest{Method: "GET", Path: docker.ConsensusPath}
+	if err := h.checkExecPolicy(user, req); err != nil {
+		respondError(c, setupError("liveness", err))
+		return
+	}
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, setupError("liveness", err))
+		return
+	}
+
+	ctx, cancel := h.chainContext(c.Request.Context(), req.Path)
+	defer cancel()
+	resp, err := dm.DoChainRequest(ctx, user.ContainerID, req)
+	if err == nil {
+		err = resp.Err()
+	}
+	if err != nil {
+		respondData(c, http.StatusOK, gin.H{"step": "liveness", "alive": false, "reason": chainError(err)})
+		return
+	}
+	respondData(c, http.StatusOK, gin.H{"step": "liveness", "alive": true, "consensus": resp.Value()})
+}
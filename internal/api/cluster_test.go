@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/database"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateClusterFactoryValidation(t *testing.T) {
+	handler := setupTestHandler()
+	handler.DB.(*database.MockDatabase).SaveUser(&models.User{ID: "student"})
+
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "student")
+	})
+	router.POST("/create-cluster-factory", handler.CreateClusterFactory)
+	router.POST("/cluster/start", handler.StartCluster)
+
+	for _, body := range []string{
+		`{"nodeCount":0,"stakeQuota":9999,"windowSize":4}`,
+		`{"nodeCount":100,"stakeQuota":9999,"windowSize":4}`,
+		`{"nodeCount":4,"stakeQuota":0,"windowSize":4}`,
+		`{"nodeCount":"4"}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/create-cluster-factory", bytes.NewBufferString(body))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	// 尚未创建容器
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/cluster/start", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"step":"cluster-start"`)
+	assert.Contains(t, w.Body.String(), `"code":"`+ErrCodeNoContainer+`"`)
+}
+
+func TestSetupStepsFollowExecPolicy(t *testing.T) {
+	platform, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}))
+	// 实验禁止编译和查询共识状态
+	handler.DB.SaveLab(&models.Lab{ID: "lab1", ExecRules: models.ExecPolicy{
+		{Path: "/setup/build/chain", Methods: []string{"GET"}},
+		{Path: "/proxy/*/consensus", Methods: []string{"POST"}},
+	}})
+	handler.DB.SaveUser(&models.User{ID: "student", ContainerID: "c1", LabID: "lab1"})
+	handler.Jobs = NewJobManager(handler)
+	handler.Pipelines = NewPipelineRunner(handler)
+	handler.Config.Pipelines = []models.Pipeline{{
+		Name:  "build",
+		Steps: []models.PipelineStep{{Name: "build-chain", Path: "/setup/build/chain"}},
+	}}
+
+	// 同步请求和后台任务都受策略约束
+	for _, path := range []string{"/api/build-blockchain", "/api/build-blockchain?async=true"} {
+		code, _, body := doChainRequest(t, platform, "POST", path, "", nil)
+		assert.Equal(t, http.StatusForbidden, code, path)
+		assert.Contains(t, body, `"code":"`+models.ExecErrMethodNotAllowed+`"`, path)
+		assert.Contains(t, body, `"step":"build-chain"`, path)
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "student")
+	})
+	router.GET("/cluster/liveness", handler.ClusterLiveness)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/cluster/liveness", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"step":"liveness"`)
+
+	// 流水线中不允许的步骤直接失败，不会请求容器
+	code, _, body := doChainRequest(t, platform, "POST", "/api/pipelines/build/run", "", nil)
+	assert.Equal(t, http.StatusAccepted, code)
+	var accepted struct {
+		Data pipelineAccepted `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(body), &accepted))
+	run := waitPipelineRun(t, handler, accepted.Data.RunID)
+	assert.Equal(t, models.PipelineFailed, run.Status)
+	assert.Equal(t, 0, run.Steps[0].Attempts)
+	assert.Contains(t, run.Steps[0].Error, "not allowed")
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventWatcher 订阅 Docker 容器事件，按容器标签将事件对应到用户，同步用户记录中的容器状态，
+// 保存每个用户最近的状态变化并推送给已连接的客户端
+type EventWatcher struct {
+	h *Handler
+
+	mu          sync.Mutex
+	history     map[string][]docker.ContainerEvent // 用户ID -> 最近的事件
+	subscribers map[string]map[chan docker.ContainerEvent]struct{}
+	owners      map[string]string                  // 容器ID -> 用户ID，用于标签为占位用户的池容器
+	pending     map[string][]docker.ContainerEvent // 用户ID -> 待同步到用户记录的事件，有键时同步 goroutine 在运行
+}
+
+func NewEventWatcher(h *Handler) *EventWatcher {
+	return &EventWatcher{
+		h:           h,
+		history:     make(map[string][]docker.ContainerEvent),
+		subscribers: make(map[string]map[chan docker.ContainerEvent]struct{}),
+		owners:      make(map[string]string),
+		pending:     make(map[string][]docker.ContainerEvent),
+	}
+}
+
+// Run 加载已有容器的归属，然后订阅所有主机的事件流，直到 ctx 结束
+func (w *EventWatcher) Run(ctx context.Context) {
+	w.loadOwners()
+
+	var wg sync.WaitGroup
+	for _, host := range w.h.Docker.Hosts() {
+		wg.Add(1)
+		go func(host *docker.DockerManager) {
+			defer wg.Done()
+			w.watch(ctx, host)
+		}(host)
+	}
+	wg.Wait()
+}
+
+// watch 持续订阅一台主机的事件流，断开后稍后重连，直到 ctx 结束
+func (w *EventWatcher) watch(ctx context.Context, host *docker.DockerManager) {
+	for {
+		err := host.WatchEvents(ctx, w.handle)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Docker event stream of host %s closed: %v, reconnecting", host.Name(), err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// handle 处理单个容器事件：记录历史并推送给客户端，用户记录的更新交给该用户的同步队列，
+// 不在事件流中等待用户锁。无标签的容器和无法对应到用户的事件（如未分配的池容器）被忽略
+func (w *EventWatcher) handle(event docker.ContainerEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	userID := w.resolveOwner(event)
+	if userID == "" {
+		return
+	}
+	event.Owner = userID
+	if event.Action == "destroy" {
+		delete(w.owners, event.ContainerID)
+	}
+	w.enqueue(userID, event)
+
+	history := append(w.history[userID], event)
+	if max := w.h.cfg().EventHistorySize; len(history) > max {
+		history = history[len(history)-max:]
+	}
+	w.history[userID] = history
+	for ch := range w.subscribers[userID] {
+		// 客户端处理过慢时丢弃事件，不阻塞事件流
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// resolveOwner 返回容器标签中的用户。池容器分配后标签仍为占位用户，此时按容器ID查找接管它的用户。
+// 调用方需持有锁
+func (w *EventWatcher) resolveOwner(event docker.ContainerEvent) string {
+	if !strings.HasPrefix(event.Owner, poolUserPrefix) {
+		return event.Owner
+	}
+	return w.owners[event.ContainerID]
+}
+
+// loadOwners 从用户记录加载容器归属，服务重启前已分配的池容器的事件仍能对应到用户
+func (w *EventWatcher) loadOwners() {
+	users, err := w.h.DB.ListUsers()
+	if err != nil {
+		log.Printf("Failed to list users: %v", err)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, user := range users {
+		if user.ContainerID != "" {
+			w.owners[user.ContainerID] = user.ID
+		}
+	}
+}
+
+// assign 记录池容器已由 userID 接管
+func (w *EventWatcher) assign(containerID, userID string) {
+	w.mu.Lock()
+	w.owners[containerID] = userID
+	w.mu.Unlock()
+}
+
+// enqueue 将事件加入用户的同步队列，队列为空时启动 goroutine 按顺序处理。调用方需持有锁
+func (w *EventWatcher) enqueue(userID string, event docker.ContainerEvent) {
+	pending, running := w.pending[userID]
+	w.pending[userID] = append(pending, event)
+	if !running {
+		go w.drain(userID)
+	}
+}
+
+// drain 依次将用户队列中的事件同步到用户记录，队列处理完后退出
+func (w *EventWatcher) drain(userID string) {
+	for {
+		w.mu.Lock()
+		events := w.pending[userID]
+		if len(events) == 0 {
+			delete(w.pending, userID)
+			w.mu.Unlock()
+			return
+		}
+		w.pending[userID] = nil
+		w.mu.Unlock()
+
+		for _, event := range events {
+			w.syncUser(userID, event)
+		}
+	}
+}
+
+// syncUser 根据事件更新用户记录中的容器状态，只处理用户当前的容器
+func (w *EventWatcher) syncUser(userID string, event docker.ContainerEvent) {
+	user, unlock, err := w.h.lockUser(userID)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	if user.ContainerID != event.ContainerID {
+		return
+	}
+
+	state := user.ContainerState
+	switch event.Action {
+	case "start", "restart":
+		state = "running"
+	case "oom":
+		state = "oom-killed"
+	case "die":
+		// oom 事件之后紧跟 die 事件，保留更具体的原因
+		if state != "oom-killed" {
+			state = "exited"
+		}
+	case "health_status":
+		if event.Detail == "unhealthy" {
+			state = "unhealthy"
+		} else if event.Detail == "healthy" {
+			state = "running"
+		}
+	case "destroy":
+		state = "removed"
+		user.ContainerID = ""
+	}
+	if state == user.ContainerState && event.Action != "destroy" {
+		return
+	}
+	user.ContainerState = state
+	if err := w.h.DB.SaveUser(user); err != nil {
+		log.Printf("Failed to update container state of user %s: %v", userID, err)
+	}
+}
+
+// History 返回用户最近的容器事件
+func (w *EventWatcher) History(userID string) []docker.ContainerEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]docker.ContainerEvent(nil), w.history[userID]...)
+}
+
+// Subscribe 订阅用户的容器事件，调用返回的函数取消订阅
+func (w *EventWatcher) Subscribe(userID string) (<-chan docker.ContainerEvent, func()) {
+	ch := make(chan docker.ContainerEvent, 16)
+	w.mu.Lock()
+	if w.subscribers[userID] == nil {
+		w.subscribers[userID] = make(map[chan docker.ContainerEvent]struct{})
+	}
+	w.subscribers[userID][ch] = struct{}{}
+	w.mu.Unlock()
+
+	return ch, func() {
+		w.mu.Lock()
+		delete(w.subscribers[userID], ch)
+		if len(w.subscribers[userID]) == 0 {
+			delete(w.subscribers, userID)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// ContainerEvents 以 Server-Sent Events 推送当前用户的容器事件，先发送最近的历史事件
+func (h *Handler) ContainerEvents(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if h.Events == nil {
+		respondError(c, newAPIError(http.StatusServiceUnavailable, "Event watcher is not running"))
+		return
+	}
+
+	events, unsubscribe := h.Events.Subscribe(userID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	for _, event := range h.Events.History(userID) {
+		c.SSEvent("state", event)
+	}
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			c.SSEvent("state", event)
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/database"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitSynced 等待用户的事件全部同步到用户记录
+func waitSynced(t *testing.T, w *EventWatcher, userID string) {
+	assert.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		_, running := w.pending[userID]
+		return !running
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestEventWatcherTracksUserState(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "student", ContainerID: "c1", ContainerState: "running"})
+	watcher := NewEventWatcher(handler)
+
+	events, unsubscribe := watcher.Subscribe("student")
+	defer unsubscribe()
+
+	watcher.handle(docker.ContainerEvent{ContainerID: "c1", Owner: "student", Action: "oom"})
+	watcher.handle(docker.ContainerEvent{ContainerID: "c1", Owner: "student", Action: "die", ExitCode: "137"})
+	waitSynced(t, watcher, "student")
+	assert.Equal(t, "oom-killed", mockDB.Users["student"].ContainerState)
+
+	// 已分配的池容器标签仍为占位用户，按接管记录对应到学生
+	watcher.assign("c1", "student")
+	watcher.handle(docker.ContainerEvent{ContainerID: "c1", Owner: poolUserPrefix + "abc", Action: "start"})
+	waitSynced(t, watcher, "student")
+	assert.Equal(t, "running", mockDB.Users["student"].ContainerState)
+
+	// 无标签的容器和未分配的池容器的事件被忽略
+	watcher.handle(docker.ContainerEvent{ContainerID: "c1", Action: "stop"})
+	watcher.handle(docker.ContainerEvent{ContainerID: "c2", Owner: poolUserPrefix + "def", Action: "start"})
+
+	history := watcher.History("student")
+	assert.Len(t, history, 3)
+	assert.Equal(t, "137", history[1].ExitCode)
+	assert.Equal(t, "oom", (<-events).Action)
+
+	watcher.handle(docker.ContainerEvent{ContainerID: "c1", Owner: "student", Action: "destroy"})
+	waitSynced(t, watcher, "student")
+	assert.Equal(t, "", mockDB.Users["student"].ContainerID)
+	assert.Equal(t, "removed", mockDB.Users["student"].ContainerState)
+}
+
+func TestEventWatcherDoesNotWaitForUserLock(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "student", ContainerID: "c1", ContainerState: "running"})
+	watcher := NewEventWatcher(handler)
+
+	// 用户锁被长时间操作持有时，事件仍立即推送，锁释放后再同步用户记录
+	unlock := handler.locks.lock("student")
+	watcher.handle(docker.ContainerEvent{ContainerID: "c1", Owner: "student", Action: "die"})
+	assert.Len(t, watcher.History("student"), 1)
+	assert.Equal(t, "running", mockDB.Users["student"].ContainerState)
+
+	unlock()
+	waitSynced(t, watcher, "student")
+	assert.Equal(t, "exited", mockDB.Users["student"].ContainerState)
+}
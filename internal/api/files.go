@@ -0,0 +1,247 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"github.com/docker/docker/errdefs"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// containerPath 校验容器内路径位于允许的目录下，返回清理后的路径
+func (h *Handler) containerPath(p string) (string, error) {
+	if !path.IsAbs(p) {
+		return "", &httpError{http.StatusBadRequest, "Path must be absolute"}
+	}
+	p = path.Clean(p)
+	for _, allowed := range h.cfg().FileAllowedPaths {
+		allowed = path.Clean(allowed)
+		if p == allowed || strings.HasPrefix(p, allowed+"/") {
+			return p, nil
+		}
+	}
+	return "", &httpError{http.StatusForbidden, "Path is not allowed"}
+}
+
+// UploadFile 上传文件到容器内 path 指定的目录。扩展名为 .tar 的文件在 extract=true 时解压到该目录
+func (h *Handler) UploadFile(c *gin.Context) {
+	dir, err := h.containerPath(c.Query("path"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.cfg().MaxUploadSize)
+	header, err := c.FormFile("file")
+	if err != nil {
+		respondError(c, newAPIError(http.StatusBadRequest, "Invalid upload: "+err.Error()))
+		return
+	}
+	if header.Size > h.cfg().MaxUploadSize {
+		respondError(c, newAPIError(http.StatusRequestEntityTooLarge, "File is too large"))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	defer file.Close()
+
+	var archive bytes.Buffer
+	if c.Query("extract") == "true" && strings.HasSuffix(header.Filename, ".tar") {
+		err = sanitizeTar(&archive, file)
+	} else {
+		err = singleFileTar(&archive, path.Base(header.Filename), file, header.Size)
+	}
+	if err != nil {
+		respondError(c, newAPIError(http.StatusBadRequest, "Invalid archive: "+err.Error()))
+		return
+	}
+
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := dm.CopyToContainer(c.Request.Context(), user.ContainerID, dir, &archive); err != nil {
+		if errdefs.IsNotFound(err) {
+			respondError(c, newAPIError(http.StatusNotFound, "Container or directory not found"))
+			return
+		}
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to upload file: "+err.Error()))
+		return
+	}
+	respondData(c, http.StatusOK, gin.H{"path": dir})
+}
+
+// DownloadFile 以 tar（默认）或 zip 格式下载容器内的文件或目录
+func (h *Handler) DownloadFile(c *gin.Context) {
+	src, err := h.containerPath(c.Query("path"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	format := c.DefaultQuery("format", "tar")
+	if format != "tar" && format != "zip" {
+		respondError(c, newAPIError(http.StatusBadRequest, "Invalid format, must be tar or zip"))
+		return
+	}
+
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	reader, err := dm.CopyFromContainer(c.Request.Context(), user.ContainerID, src)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			respondError(c, newAPIError(http.StatusNotFound, "Container or path not found"))
+			return
+		}
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to download file: "+err.Error()))
+		return
+	}
+	defer reader.Close()
+
+	// 先写入临时文件以便在发送响应头前检查大小
+	tmp, err := os.CreateTemp("", "bts-download-*.tar")
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	maxSize := h.cfg().MaxDownloadSize
+	size, err := io.Copy(tmp, io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to download file: "+err.Error()))
+		return
+	}
+	if size > maxSize {
+		respondError(c, newAPIError(http.StatusRequestEntityTooLarge, "Download exceeds size limit"))
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	name := path.Base(src)
+	if format == "zip" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+		c.Header("Content-Type", "application/zip")
+		c.Status(http.StatusOK)
+		// 响应头已发送，转换失败时只能中断响应
+		_ = tarToZip(c.Writer, tmp)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.tar"`, name))
+	c.DataFromReader(http.StatusOK, size, "application/x-tar", tmp, nil)
+}
+
+// singleFileTar 将单个文件打包为只含该文件的 tar
+func singleFileTar(dst io.Writer, name string, src io.Reader, size int64) error {
+	tw := tar.NewWriter(dst)
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, src); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// sanitizeTar 复制上传的 tar，只保留普通文件和目录，拒绝绝对路径和跳出目标目录的条目
+func sanitizeTar(dst io.Writer, src io.Reader) error {
+	tr := tar.NewReader(src)
+	tw := tar.NewWriter(dst)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("entry %q escapes target directory", hdr.Name)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir {
+			return fmt.Errorf("entry %q is not a regular file or directory", hdr.Name)
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: hdr.Typeflag,
+			Name:     hdr.Name,
+			Mode:     hdr.Mode & 0o777,
+			Size:     hdr.Size,
+			ModTime:  hdr.ModTime,
+		}); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// tarToZip 将 tar 转换为 zip，只保留普通文件和目录
+func tarToZip(dst io.Writer, src io.Reader) error {
+	tr := tar.NewReader(src)
+	zw := zip.NewWriter(dst)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if _, err := zw.Create(strings.TrimSuffix(hdr.Name, "/") + "/"); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			fh := &zip.FileHeader{Name: hdr.Name, Method: zip.Deflate, Modified: hdr.ModTime}
+			fh.SetMode(os.FileMode(hdr.Mode & 0o777))
+			w, err := zw.CreateHeader(fh)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(w, tr); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
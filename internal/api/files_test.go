@@ -0,0 +1,58 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerPathAllowList(t *testing.T) {
+	handler := setupTestHandler()
+
+	p, err := handler.containerPath("/app/workdir/node0/../genesis.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "/app/workdir/genesis.json", p)
+
+	for _, bad := range []string{"/app/workdir/../../etc/passwd", "/app/workdirx", "/etc", "app/workdir"} {
+		_, err := handler.containerPath(bad)
+		assert.Error(t, err, bad)
+	}
+	_, err = handler.containerPath("/etc/passwd")
+	assert.Equal(t, http.StatusForbidden, err.(*httpError).StatusCode)
+}
+
+func TestSanitizeTarRejectsEscapes(t *testing.T) {
+	build := func(name string, typeflag byte) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		_ = tw.WriteHeader(&tar.Header{Name: name, Typeflag: typeflag, Mode: 0o644, Linkname: "/etc/passwd"})
+		_ = tw.Close()
+		return &buf
+	}
+
+	assert.NoError(t, sanitizeTar(io.Discard, build("node0/config.toml", tar.TypeReg)))
+	assert.Error(t, sanitizeTar(io.Discard, build("../../etc/cron.d/x", tar.TypeReg)))
+	assert.Error(t, sanitizeTar(io.Discard, build("/etc/passwd", tar.TypeReg)))
+	assert.Error(t, sanitizeTar(io.Discard, build("link", tar.TypeSymlink)))
+}
+
+func TestTarToZip(t *testing.T) {
+	var tarBuf bytes.Buffer
+	assert.NoError(t, singleFileTar(&tarBuf, "genesis.json", bytes.NewBufferString(`{"chainID":1}`), 13))
+
+	var zipBuf bytes.Buffer
+	assert.NoError(t, tarToZip(&zipBuf, &tarBuf))
+
+	zr, err := zip.NewReader(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+	assert.NoError(t, err)
+	assert.Len(t, zr.File, 1)
+	assert.Equal(t, "genesis.json", zr.File[0].Name)
+	f, _ := zr.File[0].Open()
+	content, _ := io.ReadAll(f)
+	assert.Equal(t, `{"chainID":1}`, string(content))
+}
@@ -1,20 +1,66 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/cynic-1/blockchain-teaching-system/internal/auth"
+	"github.com/cynic-1/blockchain-teaching-system/internal/config"
 	"github.com/cynic-1/blockchain-teaching-system/internal/database"
 	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
 	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/cynic-1/blockchain-teaching-system/internal/ports"
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type Handler struct {
 	DB     database.DatabaseInterface
 	Docker *docker.DockerManager
+	Config *config.Config
+	Ports  *ports.Allocator
+	Pool   *ContainerPool
+	Queue  *docker.Queue
+	Events *EventWatcher
+	Stats  *StatsCollector
+	Jobs   *JobManager
+	// Pipelines 一键搭建流水线的执行器
+	Pipelines *PipelineRunner
+
+	locks  userLocks
+	busy   busyUsers
+	limits rateLimits
+	probes livenessProbes
 }
 
+// dockerFor 返回用户容器所在的 Docker 主机。主机已从配置中移除时返回 503，
+// 不在其他主机上操作用户的容器
+func (h *Handler) dockerFor(user *models.User) (*docker.DockerManager, error) {
+	dm, err := h.Docker.Host(user.DockerHost)
+	if err != nil {
+		return nil, &httpError{StatusCode: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+	return dm, nil
+}
+
+// cfg 返回处理器配置，未设置时使用默认配置
+func (h *Handler) cfg() *config.Config {
+	if h.Config == nil {
+		return config.NewConfig()
+	}
+	return h.Config
+}
+
+// 创建容器时已有容器的处理方式
+const (
+	CreateModeReuse    = "reuse"    // 复用已有容器
+	CreateModeRecreate = "recreate" // 删除已有容器后重新创建
+	CreateModeFail     = "fail"     // 已有容器时返回冲突错误
+)
+
 // httpError 用于封装 HTTP 错误
 type httpError struct {
 	StatusCode int
@@ -26,16 +72,25 @@ func (e *httpError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
 }
 
-// getUserFromContext 从 gin.Context 中获取用户信息
-func (h *Handler) getUserFromContext(c *gin.Context) (*models.User, error) {
+// getUserIDFromContext 从 gin.Context 中获取用户ID
+func getUserIDFromContext(c *gin.Context) (string, error) {
 	userIDInterface, exists := c.Get("userID")
 	if !exists {
-		return nil, &httpError{http.StatusBadRequest, "No userID"}
+		return "", &httpError{http.StatusBadRequest, "No userID"}
 	}
 
 	userID, ok := userIDInterface.(string)
 	if !ok {
-		return nil, &httpError{http.StatusInternalServerError, "UserID is not a string"}
+		return "", &httpError{http.StatusInternalServerError, "UserID is not a string"}
+	}
+	return userID, nil
+}
+
+// getUserFromContext 从 gin.Context 中获取用户信息
+func (h *Handler) getUserFromContext(c *gin.Context) (*models.User, error) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return nil, err
 	}
 
 	user, err := h.DB.GetUser(userID)
@@ -47,11 +102,32 @@ func (h *Handler) getUserFromContext(c *gin.Context) (*models.User, error) {
 }
 
 func (h *Handler) Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	// 只接受用户ID和密码，容器、网络、数据卷等字段由平台分配
+	var req struct {
+		UserID   string `json:"userID"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// 用户ID用作网络、数据卷和快照目录的名称，只允许字母、数字和 _ . -，
+	// 不能包含 ":"，也避免与其他数据的键前缀冲突；"pool-" 前缀保留给预热池
+	if !docker.IsSafeName(req.UserID) || strings.HasPrefix(req.UserID, poolUserPrefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userID"})
+		return
+	}
+
+	// 注册的用户均为学生，管理员角色只在服务启动时按配置授予，实验由管理员分配
+	user := models.User{ID: req.UserID, Password: req.Password, Role: models.RoleStudent}
+
+	// 持有用户锁，避免并发注册同一ID时互相覆盖
+	unlock := h.locks.lock(user.ID)
+	defer unlock()
+	if _, err := h.DB.GetUser(user.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		return
+	}
 
 	hashedPassword, err := auth.HashPassword(user.Password)
 	if err != nil {
@@ -101,111 +177,445 @@ func (h *Handler) Login(c *gin.Context) {
 	})
 }
 
+// lockUserFromContext 锁定当前用户并在锁内重新读取用户记录，调用方需在结束后调用返回的解锁函数
+func (h *Handler) lockUserFromContext(c *gin.Context) (*models.User, func(), error) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h.lockUser(userID)
+}
+
+// lockUser 锁定指定用户并在锁内读取用户记录
+func (h *Handler) lockUser(userID string) (*models.User, func(), error) {
+	unlock := h.locks.lock(userID)
+	user, err := h.DB.GetUser(userID)
+	if err != nil {
+		unlock()
+		return nil, nil, &httpError{http.StatusInternalServerError, "Failed to get user"}
+	}
+	return user, unlock, nil
+}
+
 func (h *Handler) CreateContainer(c *gin.Context) {
+	mode := c.DefaultQuery("mode", CreateModeReuse)
+	if mode != CreateModeReuse && mode != CreateModeRecreate && mode != CreateModeFail {
+		respondError(c, &apiError{Status: http.StatusBadRequest, Code: ErrCodeBadRequest, Message: "Invalid mode, must be one of reuse, recreate, fail"})
+		return
+	}
+
 	user, err := h.getUserFromContext(c)
 	if err != nil {
-		if httpErr, ok := err.(*httpError); ok {
-			c.JSON(httpErr.StatusCode, gin.H{"error": httpErr.Message})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unknown error"})
+		respondError(c, err)
 		return
 	}
+	// 异步创建返回 202，配额在操作结束后按结果归还
+	refund := h.deferRefund(c, user, QuotaCreate)
+	h.runOperation(c, "create", func(ctx context.Context, userID string) (interface{}, error) {
+		result, err := h.createContainer(ctx, userID, mode)
+		// 复用已有容器没有创建新容器，与失败的创建一样不占用配额
+		if reused, ok := result.(containerResult); refund != nil && (err != nil || ok && reused.Reused) {
+			refund()
+		}
+		return result, err
+	})
+}
+
+// containerResult 容器生命周期操作成功时返回的数据
+type containerResult struct {
+	ContainerID string `json:"containerID"`
+	Port        string `json:"port,omitempty"`
+	Reused      bool   `json:"reused,omitempty"` // 沿用了已有的容器
+	Pooled      bool   `json:"pooled,omitempty"` // 接管了预热池中的容器
+}
+
+// internalError 平台内部错误
+func internalError(message string) *apiError {
+	return &apiError{Status: http.StatusInternalServerError, Code: ErrCodeInternal, Message: message}
+}
 
-	containerID, err := h.Docker.CreateContainer(c.Request.Context(), "chain-proxy", nil)
+func (h *Handler) createContainer(ctx context.Context, userID, mode string) (interface{}, error) {
+	user, unlock, err := h.lockUser(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create container"})
-		return
+		return nil, err
+	}
+	defer unlock()
+
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := dm.ContainerExists(ctx, user.ContainerID)
+	if err != nil {
+		return nil, internalError("Failed to inspect existing container")
+	}
+
+	if exists {
+		switch mode {
+		case CreateModeReuse:
+			return containerResult{ContainerID: user.ContainerID, Port: user.Port, Reused: true}, nil
+		case CreateModeFail:
+			return nil, &apiError{Status: http.StatusConflict, Code: ErrCodeConflict, Message: "Container already exists",
+				Detail: containerResult{ContainerID: user.ContainerID, Port: user.Port}}
+		case CreateModeRecreate:
+			// 与 removeContainer 相同，搭建操作执行中时不删除容器
+			release, err := h.busy.acquire(user.ID)
+			if err != nil {
+				return nil, busyError(err)
+			}
+			defer release()
+		}
+	}
+
+	image, err := h.resolveImage(user)
+	if err != nil {
+		return nil, err
+	}
+
+	// 尚无数据卷、网络和端口的用户可以直接接管预热池中的容器
+	if !exists && h.Pool != nil && user.Volume == "" && user.Network == "" && user.Port == "" {
+		if pooled := h.Pool.Take(image.Reference()); pooled != nil {
+			user.ContainerID = pooled.ContainerID
+			user.Network = pooled.Network
+			user.Volume = pooled.Volume
+			user.Port = pooled.Port
+			user.DockerHost = pooled.DockerHost
+			if err := h.DB.SaveUser(user); err != nil {
+				h.Pool.destroy(ctx, pooled)
+				return nil, internalError("Failed to update user")
+			}
+			// 池容器的标签仍为占位用户，由事件监听按容器ID对应到学生
+			if h.Events != nil {
+				h.Events.assign(user.ContainerID, user.ID)
+			}
+			return containerResult{ContainerID: user.ContainerID, Port: user.Port, Pooled: true}, nil
+		}
+	}
+
+	if err := h.scheduleHost(ctx, user); err != nil {
+		return nil, &apiError{Status: http.StatusServiceUnavailable, Code: ErrCodeUnavailable, Message: "Failed to schedule container: " + err.Error()}
+	}
+	if dm, err = h.dockerFor(user); err != nil {
+		return nil, err
+	}
+
+	// 按需拉取并校验镜像
+	err = dm.EnsureImage(ctx, image.Reference(), image.Digest, func(p docker.PullProgress) {
+		log.Printf("pull %s: %s %s %s", image.Reference(), p.ID, p.Status, p.Progress)
+	})
+	if err != nil {
+		return nil, internalError("Failed to prepare image: " + err.Error())
+	}
+
+	if exists && mode == CreateModeRecreate {
+		if err := dm.RemoveContainer(ctx, user.ContainerID); err != nil {
+			return nil, internalError("Failed to remove existing container")
+		}
+	}
+
+	containerID, err := h.createUserContainer(ctx, user, image, nil)
+	if err != nil {
+		// 旧容器可能已被删除，清理用户记录中失效的容器ID
+		if user.ContainerID != "" {
+			user.ContainerID = ""
+			_ = h.DB.SaveUser(user)
+		}
+		return nil, internalError("Failed to create container")
 	}
 
 	user.ContainerID = containerID
 	if err := h.DB.SaveUser(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-		return
+		// 用户记录保存失败时回滚，避免遗留无人引用的容器和端口
+		_ = dm.RemoveContainer(ctx, containerID)
+		h.releasePort(user)
+		return nil, internalError("Failed to update user")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"Successfully created container": containerID})
+	return containerResult{ContainerID: containerID, Port: user.Port}, nil
 }
 
-func (h *Handler) StartContainer(c *gin.Context) {
-	user, err := h.getUserFromContext(c)
+// scheduleHost 为尚无网络和数据卷的用户选择负载最低的 Docker 主机，
+// 已有数据卷的用户固定在数据卷所在的主机上
+func (h *Handler) scheduleHost(ctx context.Context, user *models.User) error {
+	if user.DockerHost != "" || user.Volume != "" || user.Network != "" {
+		return nil
+	}
+	host, err := h.Docker.Schedule(ctx)
 	if err != nil {
-		if httpErr, ok := err.(*httpError); ok {
-			c.JSON(httpErr.StatusCode, gin.H{"error": httpErr.Message})
-			return
+		return err
+	}
+	user.DockerHost = host.Name()
+	return nil
+}
+
+// createUserContainer 为用户创建容器：容器只连接到用户专属网络、挂载用户数据卷并发布端口，
+// 用户尚无端口时分配新端口，创建失败时释放。extraLabels 会附加到容器标签上
+func (h *Handler) createUserContainer(ctx context.Context, user *models.User, image *models.Image, extraLabels map[string]string) (string, error) {
+	if err := h.scheduleHost(ctx, user); err != nil {
+		return "", err
+	}
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		return "", err
+	}
+
+	if user.Network == "" {
+		user.Network = docker.ResourceName(h.cfg().NetworkPrefix, user.ID)
+	}
+	if err := allowPoolOwner(dm.EnsureNetwork(ctx, user.Network, map[string]string{docker.LabelOwner: user.ID})); err != nil {
+		return "", err
+	}
+	if err := h.connectInstructors(ctx, dm, user.Network); err != nil {
+		return "", err
+	}
+	// 数据卷与账号绑定，删除容器时保留
+	if user.Volume == "" {
+		user.Volume = docker.ResourceName(h.cfg().VolumePrefix, user.ID)
+	}
+	if err := allowPoolOwner(dm.EnsureVolume(ctx, user.Volume, map[string]string{docker.LabelOwner: user.ID})); err != nil {
+		return "", err
+	}
+
+	newPort := 0
+	if h.Ports != nil && user.Port == "" {
+		port, err := h.Ports.Allocate()
+		if err != nil {
+			return "", err
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unknown error"})
-		return
+		newPort = port
+		user.Port = strconv.Itoa(port)
 	}
 
-	err = h.Docker.StartContainer(c.Request.Context(), user.ContainerID)
+	labels := map[string]string{
+		docker.LabelOwner: user.ID,
+		docker.LabelImage: image.ID,
+	}
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	containerID, err := dm.CreateContainerWithOptions(ctx, docker.ContainerOptions{
+		Image:        image.Reference(),
+		Labels:       labels,
+		CPUs:         image.Resources.CPUs,
+		MemoryMB:     image.Resources.MemoryMB,
+		PortBindings: h.portBindings(user),
+		Network:      user.Network,
+		Volume:       user.Volume,
+		VolumeTarget: h.cfg().ClusterWorkDir,
+
+		HealthCmd:      h.cfg().HealthCheckCmd,
+		HealthInterval: h.cfg().HealthCheckInterval,
+		HealthRetries:  h.cfg().HealthCheckRetries,
+		RestartPolicy:  h.cfg().RestartPolicy,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start container"})
-		return
+		if newPort != 0 {
+			h.releasePort(user)
+		}
+		return "", err
 	}
+	return containerID, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"Successfully started container": user.ContainerID})
+// connectInstructors 将教师工具容器连接到学生网络，代替所有学生共用的网络
+func (h *Handler) connectInstructors(ctx context.Context, dm *docker.DockerManager, network string) error {
+	for _, name := range h.cfg().InstructorContainers {
+		if err := dm.ConnectNetwork(ctx, network, name); err != nil {
+			return fmt.Errorf("connect %s to network %s: %w", name, network, err)
+		}
+	}
+	return nil
 }
 
-func (h *Handler) StopContainer(c *gin.Context) {
-	user, err := h.getUserFromContext(c)
+// removeUserNetwork 断开教师工具容器后删除学生网络，网络上仍有其他容器时删除失败
+func (h *Handler) removeUserNetwork(ctx context.Context, dm *docker.DockerManager, network string) error {
+	for _, name := range h.cfg().InstructorContainers {
+		if err := dm.DisconnectNetwork(ctx, network, name); err != nil {
+			log.Printf("Failed to disconnect %s from network %s: %v", name, network, err)
+		}
+	}
+	return dm.RemoveNetwork(ctx, network)
+}
+
+// allowPoolOwner 忽略属于预热池占位用户的 *docker.OwnerError：学生接管池容器后沿用其网络和数据卷
+func allowPoolOwner(err error) error {
+	var ownerErr *docker.OwnerError
+	if errors.As(err, &ownerErr) && strings.HasPrefix(ownerErr.Owner, poolUserPrefix) {
+		return nil
+	}
+	return err
+}
+
+// portBindings 根据用户端口计算容器端口到宿主机端口的映射
+func (h *Handler) portBindings(user *models.User) map[string]int {
+	basePort, err := strconv.Atoi(user.Port)
+	if h.Ports == nil || err != nil {
+		return nil
+	}
+	bindings := make(map[string]int)
+	for i, containerPort := range h.cfg().PublishedPorts {
+		bindings[containerPort] = basePort + i
+	}
+	return bindings
+}
+
+// releasePort 释放用户占用的宿主机端口并清空用户记录中的端口
+func (h *Handler) releasePort(user *models.User) {
+	if h.Ports != nil {
+		if port, err := strconv.Atoi(user.Port); err == nil {
+			h.Ports.Release(port)
+		}
+	}
+	user.Port = ""
+}
+
+// resolveImage 根据用户所在实验确定容器镜像，未指定时使用默认镜像
+func (h *Handler) resolveImage(user *models.User) (*models.Image, error) {
+	defaultImage := &models.Image{Name: h.cfg().DefaultImage}
+	if user.LabID == "" {
+		return defaultImage, nil
+	}
+
+	lab, err := h.DB.GetLab(user.LabID)
 	if err != nil {
-		handleHttpError(c, err)
-		return
+		return nil, &httpError{http.StatusInternalServerError, "Failed to get lab"}
+	}
+	if lab.ImageID == "" {
+		return defaultImage, nil
 	}
 
-	err = h.Docker.StopContainer(c.Request.Context(), user.ContainerID)
+	image, err := h.DB.GetImage(lab.ImageID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, &httpError{http.StatusInternalServerError, "Failed to get lab image"}
 	}
-	c.JSON(http.StatusOK, gin.H{"result": "Successfully Stopped Container"})
+	return image, nil
+}
+
+func (h *Handler) StartContainer(c *gin.Context) {
+	h.runOperation(c, "start", func(ctx context.Context, userID string) (interface{}, error) {
+		user, unlock, err := h.lockUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+
+		if user.ContainerID == "" {
+			return nil, noContainerError()
+		}
+		dm, err := h.dockerFor(user)
+		if err != nil {
+			return nil, err
+		}
+		if err := dm.StartContainer(ctx, user.ContainerID); err != nil {
+			return nil, internalError("Failed to start container")
+		}
+		return containerResult{ContainerID: user.ContainerID, Port: user.Port}, nil
+	})
+}
+
+func (h *Handler) StopContainer(c *gin.Context) {
+	h.runOperation(c, "stop", func(ctx context.Context, userID string) (interface{}, error) {
+		user, unlock, err := h.lockUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+
+		if user.ContainerID == "" {
+			return nil, noContainerError()
+		}
+		dm, err := h.dockerFor(user)
+		if err != nil {
+			return nil, err
+		}
+		if err := dm.StopContainer(ctx, user.ContainerID); err != nil {
+			return nil, internalError(err.Error())
+		}
+		return containerResult{ContainerID: user.ContainerID}, nil
+	})
 }
 
 func (h *Handler) RemoveContainer(c *gin.Context) {
-	user, err := h.getUserFromContext(c)
+	h.runOperation(c, "remove", h.removeContainer)
+}
+
+func (h *Handler) removeContainer(ctx context.Context, userID string) (interface{}, error) {
+	user, unlock, err := h.lockUser(userID)
 	if err != nil {
-		handleHttpError(c, err)
-		return
+		return nil, err
 	}
+	defer unlock()
 
-	err = h.Docker.RemoveContainer(c.Request.Context(), user.ContainerID)
+	if user.ContainerID == "" {
+		return nil, noContainerError()
+	}
+	// 搭建任务、流水线或同步搭建步骤执行中时不删除容器
+	release, err := h.busy.acquire(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, busyError(err)
+	}
+	defer release()
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := dm.RemoveContainer(ctx, user.ContainerID); err != nil {
+		return nil, internalError(err.Error())
+	}
+
+	result := containerResult{ContainerID: user.ContainerID}
+	user.ContainerID = ""
+	h.releasePort(user)
+	if user.Network != "" {
+		// 网络删除失败时保留记录，下次创建容器时复用
+		if err := h.removeUserNetwork(ctx, dm, user.Network); err != nil {
+			log.Printf("Failed to remove network %s of user %s: %v", user.Network, user.ID, err)
+		} else {
+			user.Network = ""
+		}
+	}
+	if err := h.DB.SaveUser(user); err != nil {
+		return nil, internalError("Failed to update user")
 	}
-	c.JSON(http.StatusOK, gin.H{"result": "Successfully Removed Container"})
+	return result, nil
 }
 
 // 辅助函数，用于处理 HTTP 错误
 func handleHttpError(c *gin.Context, err error) {
 	if httpErr, ok := err.(*httpError); ok {
 		c.JSON(httpErr.StatusCode, gin.H{"error": httpErr.Message})
-	} else {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unknown error"})
+		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Unknown error"})
 }
 
+// Exec 将 mis 命令转发到容器内的 chain-proxy，以 {data, error} 格式返回解析后的结果。
+// method 为空时为 POST，状态查询等只读接口应使用 GET；方法、路径和请求体受 exec 策略约束，
+// 请求头只能使用 ExecHeaders 中的名称
 func (h *Handler) Exec(c *gin.Context) {
 	var command struct {
-		Cmd []string `json:"cmd"`
+		Cmd     []string          `json:"cmd"`
+		Method  string            `json:"method"`
+		Query   map[string]string `json:"query"`
+		Headers map[string]string `json:"headers"`
 	}
 	if err := c.ShouldBindJSON(&command); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, &apiError{Status: http.StatusBadRequest, Code: ErrCodeBadRequest, Message: err.Error()})
 		return
 	}
 
-	if len(command.Cmd) < 2 || command.Cmd[0] != "mis" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Command"})
+	if len(command.Cmd) < 2 || len(command.Cmd) > 3 || command.Cmd[0] != "mis" {
+		respondError(c, &apiError{Status: http.StatusBadRequest, Code: ErrCodeBadRequest, Message: "Invalid Command"})
+		return
 	}
 
 	user, err := h.getUserFromContext(c)
 	if err != nil {
-		if httpErr, ok := err.(*httpError); ok {
-			c.JSON(httpErr.StatusCode, gin.H{"error": httpErr.Message})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unknown error"})
+		respondError(c, err)
+		return
+	}
+	if user.ContainerID == "" {
+		respondError(c, noContainerError())
 		return
 	}
 
@@ -213,13 +623,102 @@ func (h *Handler) Exec(c *gin.Context) {
 	if len(command.Cmd) > 2 {
 		body = command.Cmd[2]
 	}
-	output, err := h.Docker.SendRequest(user.ContainerID, command.Cmd[1], body)
+	method := strings.ToUpper(command.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	// 策略检查和实际请求使用同一个解码后的路径
+	path, query, err := parseChainPath(command.Cmd[1])
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for key, value := range command.Query {
+		query.Set(key, value)
+	}
+	header := make(http.Header)
+	for key, value := range command.Headers {
+		header.Set(key, value)
+	}
 
+	policy, err := h.execPolicy(user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err, "output": output})
+		respondError(c, err)
+		return
+	}
+	if err := policy.Check(method, path, body); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := models.CheckHeaders(h.cfg().ExecHeaders, header); err != nil {
+		respondError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"output": output})
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if isSetupPath(path) {
+		release, err := h.busy.acquire(user.ID)
+		if err != nil {
+			respondError(c, busyError(err))
+			return
+		}
+		defer release()
+	}
+	if isBuildPath(path) {
+		if !h.takeQuota(c, user, QuotaBuildChain) {
+			return
+		}
+		defer h.refundOnFailure(c, user, QuotaBuildChain)
+	}
+
+	ctx, cancel := h.chainContext(c.Request.Context(), path)
+	defer cancel()
+	resp, err := dm.DoChainRequest(ctx, user.ContainerID, docker.ChainRequest{
+		Method: method,
+		Path:   path,
+		Query:  query,
+		Header: header,
+		Body:   []byte(body),
+	})
+	if err == nil {
+		err = resp.Err()
+	}
+	if err != nil {
+		respondError(c, chainError(err))
+		return
+	}
+	respondData(c, http.StatusOK, chainResult{StatusCode: resp.StatusCode, Result: resp.Value()})
+}
+
+// execPolicy 返回用户适用的 exec 策略：实验规则优先，其后为默认策略
+func (h *Handler) execPolicy(user *models.User) (models.ExecPolicy, error) {
+	if user.LabID == "" {
+		return h.cfg().ExecPolicy, nil
+	}
+	lab, err := h.DB.GetLab(user.LabID)
+	if err != nil {
+		return nil, &httpError{http.StatusInternalServerError, "Failed to get lab"}
+	}
+	policy := append(models.ExecPolicy{}, lab.ExecRules...)
+	return append(policy, h.cfg().ExecPolicy...), nil
+}
+
+// checkExecPolicy 检查转发到 chain-proxy 的请求是否被用户适用的 exec 策略允许，
+// 搭建接口、后台任务和流水线步骤与 exec 受同一策略约束
+func (h *Handler) checkExecPolicy(user *models.User, req docker.ChainRequest) error {
+	policy, err := h.execPolicy(user)
+	if err != nil {
+		return err
+	}
+	// 与 DoChainRequest 一致，未指定方法时为 GET
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return policy.Check(method, req.Path, string(req.Body))
 }
 
 // 其他处理器方法...
@@ -303,148 +802,3 @@ func (h *Handler) Exec(c *gin.Context) {
 //	}
 //	c.JSON(http.StatusOK, gin.H{"block": block})
 //}
-//
-//func (h *Handler) CreateLocalClusterFactory(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	var req struct {
-//		NodeCount  int `json:"nodeCount"`
-//		StakeQuota int `json:"stakeQuota"`
-//		WindowSize int `json:"windowSize"`
-//	}
-//	if err := c.ShouldBindJSON(&req); err != nil {
-//		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-//		return
-//	}
-//
-//	result, err := h.Docker.CreateLocalClusterFactory(user.ContainerID, req.NodeCount, req.StakeQuota, req.WindowSize)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
-//
-//func (h *Handler) MakeLocalAddresses(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	result, err := h.Docker.MakeLocalAddresses(user.ContainerID)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
-//
-//func (h *Handler) MakeValidatorKeysAndStakeQuotas(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	result, err := h.Docker.MakeValidatorKeysAndStakeQuotas(user.ContainerID)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
-//
-//func (h *Handler) WriteGenesisFiles(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	result, err := h.Docker.WriteGenesisFiles(user.ContainerID)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
-//
-//func (h *Handler) CreateCluster(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	result, err := h.Docker.CreateCluster(user.ContainerID)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
-//
-//func (h *Handler) BuildBlockchainBinary(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	result, err := h.Docker.BuildBlockchainBinary(user.ContainerID)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
-//
-//func (h *Handler) ResetWorkingDirectory(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	result, err := h.Docker.ResetWorkingDirectory(user.ContainerID)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
-//
-//func (h *Handler) StartCluster(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	result, err := h.Docker.StartCluster(user.ContainerID)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
-//
-//func (h *Handler) StopCluster(c *gin.Context) {
-//	user, err := h.getUserFromContext(c)
-//	if err != nil {
-//		handleHttpError(c, err)
-//		return
-//	}
-//
-//	result, err := h.Docker.StopCluster(user.ContainerID)
-//	if err != nil {
-//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-//		return
-//	}
-//	c.JSON(http.StatusOK, gin.H{"result": result})
-//}
@@ -2,15 +2,25 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/cynic-1/blockchain-teaching-system/internal/auth"
+	"github.com/cynic-1/blockchain-teaching-system/internal/config"
 	"github.com/cynic-1/blockchain-teaching-system/internal/database"
 	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
 	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -43,6 +53,97 @@ func TestRegister(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "User registered successfully")
 }
 
+func TestRegisterIgnoresClientRole(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.POST("/register", handler.Register)
+
+	userJSON := []byte(`{"userID":"student","password":"pw","role":"admin"}`)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(userJSON))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, _ := handler.DB.GetUser("student")
+	assert.Equal(t, models.RoleStudent, user.Role)
+}
+
+func TestRegisterExistingUser(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.POST("/register", handler.Register)
+
+	handler.DB.SaveUser(&models.User{ID: "student", Password: "hashed", ContainerID: "c1"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/register", bytes.NewBufferString(`{"userID":"student","password":"pw"}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	user, _ := handler.DB.GetUser("student")
+	assert.Equal(t, "hashed", user.Password)
+	assert.Equal(t, "c1", user.ContainerID)
+}
+
+func TestRegisterAdminIDIsStudent(t *testing.T) {
+	handler := setupTestHandler()
+	handler.Config = config.NewConfig()
+	handler.Config.AdminUsers = []string{"admin"}
+	router := gin.Default()
+	router.POST("/register", handler.Register)
+
+	// 管理员角色只在启动时按配置授予，注册同名ID不会获得管理员角色
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/register", bytes.NewBufferString(`{"userID":"admin","password":"pw"}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, _ := handler.DB.GetUser("admin")
+	assert.Equal(t, models.RoleStudent, user.Role)
+}
+
+func TestRegisterInvalidUserID(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.POST("/register", handler.Register)
+
+	// "a b" 和 "a/b" 替换非法字符后都会得到 "a-b"
+	for _, id := range []string{"", "a:b", "a b", "a/b", "..", "-lead", poolUserPrefix + "x1"} {
+		body, _ := json.Marshal(map[string]string{"userID": id, "password": "pw"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(body))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, id)
+	}
+}
+
+func TestRegisterIgnoresPlatformFields(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.POST("/register", handler.Register)
+
+	// 客户端不能指定容器、网络、数据卷，否则可挂载其他学生的数据卷
+	userJSON := []byte(`{"userID":"student","password":"pw","volume":"vol-other","network":"net-other","containerID":"other","port":"20000","dockerHost":"h2"}`)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(userJSON))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, err := handler.DB.GetUser("student")
+	assert.NoError(t, err)
+	assert.Empty(t, user.Volume)
+	assert.Empty(t, user.Network)
+	assert.Empty(t, user.ContainerID)
+	assert.Empty(t, user.Port)
+	assert.Empty(t, user.DockerHost)
+}
+
+func TestAllowPoolOwner(t *testing.T) {
+	assert.NoError(t, allowPoolOwner(nil))
+	assert.NoError(t, allowPoolOwner(&docker.OwnerError{Resource: "volume", Name: "vol-a", Owner: poolUserPrefix + "x1"}))
+	assert.Error(t, allowPoolOwner(&docker.OwnerError{Resource: "volume", Name: "vol-a", Owner: "bob"}))
+}
+
 func TestLogin(t *testing.T) {
 	handler := setupTestHandler()
 	router := gin.Default()
@@ -89,7 +190,39 @@ func TestCreateContainer(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "Successfully created container")
+	assert.Contains(t, w.Body.String(), `"containerID":"`)
+}
+
+func TestCreateContainerInvalidMode(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "testuser")
+	})
+	router.POST("/create", handler.CreateContainer)
+
+	handler.DB.SaveUser(&models.User{ID: "testuser", ContainerID: "test-container-id"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/create?mode=unknown", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp struct {
+		Data  interface{} `json:"data"`
+		Error *apiError   `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Nil(t, resp.Data)
+	if assert.NotNil(t, resp.Error) {
+		assert.Equal(t, ErrCodeBadRequest, resp.Error.Code)
+		assert.Contains(t, resp.Error.Message, "Invalid mode")
+	}
+
+	// 无效的 mode 不改动已保存的用户记录
+	stored, err := handler.DB.GetUser("testuser")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-container-id", stored.ContainerID)
 }
 
 func TestStartContainer(t *testing.T) {
@@ -109,7 +242,7 @@ func TestStartContainer(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "Successfully started container")
+	assert.Contains(t, w.Body.String(), `"containerID":"`)
 }
 
 func TestStopContainer(t *testing.T) {
@@ -129,7 +262,7 @@ func TestStopContainer(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "Successfully Stopped Container")
+	assert.Contains(t, w.Body.String(), `"containerID":"`)
 }
 
 func TestRemoveContainer(t *testing.T) {
@@ -149,7 +282,40 @@ func TestRemoveContainer(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "Successfully Removed Container")
+	assert.Contains(t, w.Body.String(), `"containerID":"`)
+}
+
+func TestContainerLifecycleErrorEnvelope(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "testuser")
+	})
+	router.POST("/start", handler.StartContainer)
+	router.POST("/stop", handler.StopContainer)
+	router.POST("/remove", handler.RemoveContainer)
+	router.GET("/operations/:id", handler.GetOperation)
+
+	// 尚未创建容器，不会请求 Docker
+	handler.DB.SaveUser(&models.User{ID: "testuser"})
+
+	for _, path := range []string{"/start", "/stop", "/remove"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		router.ServeHTTP(w, req)
+
+		var resp envelope
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Nil(t, resp.Data, path)
+		assert.Equal(t, ErrCodeNoContainer, resp.Error.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/operations/missing", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"`+ErrCodeNotFound+`"`)
 }
 
 func TestExec(t *testing.T) {
@@ -165,7 +331,7 @@ func TestExec(t *testing.T) {
 	handler.DB.(*database.MockDatabase).SaveUser(user)
 
 	command := map[string][]string{
-		"cmd": {"mis", "test", "body"},
+		"cmd": {"mis", "/setup/new/factory", `{"nodeCount":4,"stakeQuota":9999,"windowSize":4}`},
 	}
 	commandJSON, _ := json.Marshal(command)
 
@@ -174,5 +340,232 @@ func TestExec(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "output")
+	assert.Contains(t, w.Body.String(), `"data"`)
+}
+
+func TestExecRejectsDisallowedRequests(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "testuser")
+	})
+	router.POST("/exec", handler.Exec)
+
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "testuser", ContainerID: "test-container-id"})
+
+	// 被拒绝的请求不会到达容器（测试中的 DockerManager 没有客户端，转发会 panic）
+	tests := []struct {
+		cmd  []string
+		code int
+		want string
+	}{
+		{[]string{"sh", "-c", "rm -rf /"}, http.StatusBadRequest, "Invalid Command"},
+		{[]string{"mis"}, http.StatusBadRequest, "Invalid Command"},
+		{[]string{"mis", "/admin/shutdown"}, http.StatusForbidden, models.ExecErrPathNotAllowed},
+		{[]string{"mis", "/setup/../admin"}, http.StatusForbidden, models.ExecErrPathNotAllowed},
+		{[]string{"mis", "/setup/cluster/start", `{"x":1}`}, http.StatusBadRequest, models.ExecErrInvalidBody},
+		{[]string{"mis", "/setup/new/factory", `{"nodeCount":"4","stakeQuota":1,"windowSize":1}`}, http.StatusBadRequest, models.ExecErrInvalidBody},
+		{[]string{"mis", "/setup/new/factory", `{"nodeCount":4}`}, http.StatusBadRequest, models.ExecErrInvalidBody},
+	}
+	for _, tt := range tests {
+		commandJSON, _ := json.Marshal(map[string][]string{"cmd": tt.cmd})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/exec", bytes.NewBuffer(commandJSON))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.code, w.Code, tt.cmd)
+		assert.Contains(t, w.Body.String(), tt.want, tt.cmd)
+	}
+}
+
+func TestExecPolicyLabOverride(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveLab(&models.Lab{ID: "lab1", ExecRules: models.ExecPolicy{
+		{Path: "/setup/build/chain", Methods: []string{"GET"}},
+		{Path: "/proxy/*/peers", Methods: []string{"GET", "POST"}},
+	}})
+
+	policy, err := handler.execPolicy(&models.User{ID: "student", LabID: "lab1"})
+	assert.NoError(t, err)
+	assert.NoError(t, policy.Check("POST", "/proxy/-1/peers", ""))
+	// 实验规则优先于默认策略
+	err = policy.Check("POST", "/setup/build/chain", "")
+	assert.Equal(t, models.ExecErrMethodNotAllowed, err.(*models.ExecPolicyError).Code)
+
+	policy, err = handler.execPolicy(&models.User{ID: "student"})
+	assert.NoError(t, err)
+	assert.Error(t, policy.Check("POST", "/proxy/-1/peers", ""))
+	assert.NoError(t, policy.Check("POST", "/setup/build/chain", ""))
+}
+
+func TestDeleteAccount(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "testuser")
+	})
+	router.DELETE("/account", handler.DeleteAccount)
+
+	// 用户尚未创建容器、网络和数据卷
+	user := &models.User{ID: "testuser"}
+	handler.DB.(*database.MockDatabase).SaveUser(user)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/account", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err := handler.DB.GetUser("testuser")
+	assert.Error(t, err)
+}
+
+func TestCreateSnapshotQuota(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "testuser")
+	})
+	router.POST("/snapshots", handler.CreateSnapshot)
+
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "testuser", ContainerID: "test-container-id"})
+	for i := 0; i < handler.cfg().MaxSnapshotsPerUser; i++ {
+		mockDB.SaveSnapshot(&models.Snapshot{UserID: "testuser", Name: fmt.Sprintf("snap%d", i)})
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/snapshots", bytes.NewBufferString(`{"name":"../escape"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/snapshots", bytes.NewBufferString(`{"name":"extra"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Snapshot quota exceeded")
+}
+
+func TestRestoreSnapshotKeepsVolumeUntilRestored(t *testing.T) {
+	// 模拟 Docker 端点，记录删除操作，archiveStatus 为解压归档的响应状态码
+	var removed []string
+	archiveStatus := http.StatusInternalServerError
+	dockerSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[strings.Index(r.URL.Path[1:], "/")+1:]
+		owner := map[string]string{docker.LabelOwner: "student"}
+		switch {
+		case r.Method == http.MethodDelete:
+			removed = append(removed, path)
+			w.WriteHeader(http.StatusNoContent)
+		case path == "/networks/bts-net-student":
+			_ = json.NewEncoder(w).Encode(network.Inspect{Name: "bts-net-student", Labels: owner})
+		case path == "/volumes/create":
+			var opts volume.CreateOptions
+			_ = json.NewDecoder(r.Body).Decode(&opts)
+			_ = json.NewEncoder(w).Encode(volume.Volume{Name: opts.Name, Labels: owner})
+		case path == "/containers/create":
+			_ = json.NewEncoder(w).Encode(container.CreateResponse{ID: "new"})
+		case path == "/containers/new/archive":
+			w.WriteHeader(archiveStatus)
+		case path == "/containers/old/json":
+			_ = json.NewEncoder(w).Encode(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: "old"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer dockerSrv.Close()
+	dm, err := docker.NewMultiHostManager("1.41", []docker.HostConfig{
+		{Name: "lab-a", Endpoint: "tcp://" + dockerSrv.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+
+	handler := setupTestHandler()
+	handler.Docker = dm
+	handler.Config = config.NewConfig()
+	handler.Config.SnapshotDir = t.TempDir()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveUser(&models.User{ID: "student", ContainerID: "old", DockerHost: "lab-a", Volume: "bts-data-student", Network: "bts-net-student"})
+	mockDB.SaveSnapshot(&models.Snapshot{UserID: "student", Name: "s1", ImageRepository: "bts-snapshot", ImageTag: "student-s1"})
+	archivePath := handler.snapshotArchivePath("student", "s1")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(archivePath), 0o755))
+	assert.NoError(t, os.WriteFile(archivePath, nil, 0o644))
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "student")
+	})
+	router.POST("/snapshots/:name/restore", handler.RestoreSnapshot)
+	restore := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/snapshots/s1/restore", nil)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 解压失败时只清理新建的容器和数据卷，原有容器和数据卷不变
+	assert.Equal(t, http.StatusInternalServerError, restore())
+	if assert.Len(t, removed, 2) {
+		assert.Equal(t, "/containers/new", removed[0])
+		assert.True(t, strings.HasPrefix(removed[1], "/volumes/bts-data-student-"), removed[1])
+	}
+	user, _ := mockDB.GetUser("student")
+	assert.Equal(t, "old", user.ContainerID)
+	assert.Equal(t, "bts-data-student", user.Volume)
+
+	// 成功后才删除原有容器和数据卷
+	removed = nil
+	archiveStatus = http.StatusOK
+	assert.Equal(t, http.StatusOK, restore())
+	assert.Equal(t, []string{"/containers/old", "/volumes/bts-data-student"}, removed)
+	user, _ = mockDB.GetUser("student")
+	assert.Equal(t, "new", user.ContainerID)
+	assert.True(t, strings.HasPrefix(user.Volume, "bts-data-student-"))
+}
+
+func TestInstructorNetworks(t *testing.T) {
+	// 模拟 Docker 端点，记录网络操作
+	var calls []string
+	dockerSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[strings.Index(r.URL.Path[1:], "/")+1:]
+		calls = append(calls, r.Method+" "+path)
+		if r.Method == http.MethodGet && path == "/containers/tools/json" {
+			_ = json.NewEncoder(w).Encode(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "tools"},
+				NetworkSettings: &types.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+					"bts-net-bob": {},
+				}},
+			})
+		}
+	}))
+	defer dockerSrv.Close()
+	dm, err := docker.NewMultiHostManager("1.41", []docker.HostConfig{
+		{Name: "lab-a", Endpoint: "tcp://" + dockerSrv.Listener.Addr().String()},
+	})
+	assert.NoError(t, err)
+
+	handler := setupTestHandler()
+	handler.Config = config.NewConfig()
+	handler.Config.InstructorContainers = []string{"tools"}
+
+	// 教师工具连接到每个学生网络，已连接时跳过
+	assert.NoError(t, handler.connectInstructors(context.Background(), dm, "bts-net-alice"))
+	assert.NoError(t, handler.connectInstructors(context.Background(), dm, "bts-net-bob"))
+	assert.NoError(t, handler.removeUserNetwork(context.Background(), dm, "bts-net-alice"))
+	assert.Equal(t, []string{
+		"GET /containers/tools/json",
+		"POST /networks/bts-net-alice/connect",
+		"GET /containers/tools/json",
+		"POST /networks/bts-net-alice/disconnect",
+		"DELETE /networks/bts-net-alice",
+	}, calls)
+}
+
+func TestSnapshotNamePattern(t *testing.T) {
+	assert.True(t, snapshotNamePattern.MatchString("v1.0_final-2"))
+	assert.True(t, snapshotNamePattern.MatchString(strings.Repeat("a", 64)))
+	// 镜像 tag 不能以 . 或 - 开头，归档文件名不能包含路径
+	for _, name := range []string{"", ".hidden", "-x", "a/b", "a:b", strings.Repeat("a", 65)} {
+		assert.False(t, snapshotNamePattern.MatchString(name), name)
+	}
 }
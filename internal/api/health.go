@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ContainerStatus 返回当前用户容器的运行状态和健康检查结果
+func (h *Handler) ContainerStatus(c *gin.Context) {
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	exists, err := dm.ContainerExists(ctx, user.ContainerID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to inspect existing container"))
+		return
+	}
+	if !exists {
+		respondError(c, newAPIError(http.StatusNotFound, "Container not found"))
+		return
+	}
+
+	health, err := dm.InspectHealth(ctx, user.ContainerID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	respondData(c, http.StatusOK, gin.H{"containerID": user.ContainerID, "status": health})
+}
+
+// MonitorHealth 定期从宿主机探测学生容器内的 chain-proxy，重启健康检查失败的容器，直到 ctx 结束。
+// chain-proxy 崩溃时容器本身仍在运行，Docker 的重启策略不会生效
+func (h *Handler) MonitorHealth(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg().HealthMonitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		h.restartUnhealthy(ctx)
+	}
+}
+
+// restartUnhealthy 重启所有主机上 unhealthy 的学生容器
+func (h *Handler) restartUnhealthy(ctx context.Context) {
+	for _, host := range h.Docker.Hosts() {
+		containers, err := h.unhealthyContainers(ctx, host)
+		if err != nil {
+			log.Printf("Failed to list unhealthy containers on host %s: %v", host.Name(), err)
+			continue
+		}
+		for _, c := range containers {
+			h.restartContainer(ctx, host, c)
+		}
+	}
+}
+
+// restartContainer 持有用户锁重启容器，与用户的其他容器操作串行执行。
+// 用户正在执行搭建操作时跳过，连续失败次数保留到下一轮再判断
+func (h *Handler) restartContainer(ctx context.Context, host *docker.DockerManager, c docker.ContainerSummary) {
+	owner := c.Labels[docker.LabelOwner]
+	unlock := h.locks.lock(owner)
+	defer unlock()
+	if h.busy.isBusy(owner) {
+		log.Printf("Skipping restart of unhealthy container %s: user %s is busy", c.ID, owner)
+		return
+	}
+	log.Printf("Restarting unhealthy container %s of user %s", c.ID, owner)
+	if err := host.RestartContainer(ctx, c.ID); err != nil {
+		log.Printf("Failed to restart container %s: %v", c.ID, err)
+	}
+	h.probes.reset(c.ID)
+}
+
+// unhealthyContainers 返回主机上 Docker 健康检查失败，或从宿主机连续 HealthCheckRetries 次
+// 探测不到 chain-proxy 的运行中学生容器
+func (h *Handler) unhealthyContainers(ctx context.Context, host *docker.DockerManager) ([]docker.ContainerSummary, error) {
+	unhealthy, err := host.ListUnhealthyContainers(ctx, docker.LabelOwner)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := host.ListContainers(ctx, docker.LabelOwner)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(unhealthy))
+	for _, c := range unhealthy {
+		listed[c.ID] = true
+	}
+	for _, c := range containers {
+		if c.State != "running" || listed[c.ID] {
+			continue
+		}
+		if h.probes.record(c.ID, host.CheckLiveness(ctx, c.ID)) >= h.cfg().HealthCheckRetries {
+			unhealthy = append(unhealthy, c)
+		}
+	}
+	return unhealthy, nil
+}
+
+// livenessProbes 记录每个容器连续探测失败的次数
+type livenessProbes struct {
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+// record 记录一次探测结果，返回容器连续失败的次数
+func (p *livenessProbes) record(containerID string, err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		delete(p.failures, containerID)
+		return 0
+	}
+	if p.failures == nil {
+		p.failures = make(map[string]int)
+	}
+	p.failures[containerID]++
+	return p.failures[containerID]
+}
+
+// reset 容器重启后重新计数
+func (p *livenessProbes) reset(containerID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.failures, containerID)
+}
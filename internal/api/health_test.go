@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/config"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupHealthHost 模拟只有运行中容器 c1 的 Docker 主机，c1 的 8080 端口发布到 chainPort，
+// 返回主机和 c1 被重启的次数
+func setupHealthHost(t *testing.T, chainPort string) (*docker.DockerManager, *int32) {
+	var restarts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			// Docker 健康检查未配置，按 health 过滤时没有容器
+			if strings.Contains(r.URL.Query().Get("filters"), "health") {
+				_, _ = w.Write([]byte("[]"))
+				return
+			}
+			_ = json.NewEncoder(w).Encode([]types.Container{
+				{ID: "c1", State: "running", Labels: map[string]string{docker.LabelOwner: "student"}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/containers/c1/json"):
+			_ = json.NewEncoder(w).Encode(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "c1", State: &types.ContainerState{Running: true}},
+				NetworkSettings: &types.NetworkSettings{NetworkSettingsBase: types.NetworkSettingsBase{
+					Ports: nat.PortMap{"8080/tcp": []nat.PortBinding{{HostPort: chainPort}}},
+				}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/containers/c1/restart"):
+			atomic.AddInt32(&restarts, 1)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dm, err := docker.NewMultiHostManager("1.41", []docker.HostConfig{
+		{Name: "lab-a", Endpoint: "tcp://" + srv.Listener.Addr().String(), ProxyAddress: "127.0.0.1"},
+	})
+	assert.NoError(t, err)
+	return dm, &restarts
+}
+
+func TestRestartUnhealthyProbesChainProxy(t *testing.T) {
+	chain := httptest.NewServer(http.NotFoundHandler())
+	_, chainPort, _ := net.SplitHostPort(chain.Listener.Addr().String())
+	dm, restarts := setupHealthHost(t, chainPort)
+	handler := &Handler{Docker: dm, Config: config.NewConfig()}
+	handler.Config.HealthCheckRetries = 2
+
+	// chain-proxy 有响应时不重启
+	handler.restartUnhealthy(context.Background())
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+
+	// 连续失败达到 HealthCheckRetries 次后才重启
+	chain.Close()
+	handler.restartUnhealthy(context.Background())
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+	handler.restartUnhealthy(context.Background())
+	assert.Equal(t, int32(1), atomic.LoadInt32(restarts))
+
+	// 重启后重新计数
+	handler.restartUnhealthy(context.Background())
+	assert.Equal(t, int32(1), atomic.LoadInt32(restarts))
+}
+
+func TestRestartUnhealthySkipsBusyUser(t *testing.T) {
+	chain := httptest.NewServer(http.NotFoundHandler())
+	_, chainPort, _ := net.SplitHostPort(chain.Listener.Addr().String())
+	chain.Close()
+	dm, restarts := setupHealthHost(t, chainPort)
+	handler := &Handler{Docker: dm, Config: config.NewConfig()}
+	handler.Config.HealthCheckRetries = 1
+
+	// 搭建操作执行中时不重启容器
+	release, err := handler.busy.acquire("student")
+	assert.NoError(t, err)
+	handler.restartUnhealthy(context.Background())
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+
+	release()
+	handler.restartUnhealthy(context.Background())
+	assert.Equal(t, int32(1), atomic.LoadInt32(restarts))
+}
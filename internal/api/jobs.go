@@ -0,0 +1,323 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jobSaveInterval 执行中任务的输出写入数据库的最小间隔
+	jobSaveInterval = time.Second
+	// jobCleanupInterval 清理过期任务的间隔
+	jobCleanupInterval = 10 * time.Minute
+	jobTruncatedNote   = "\n[output truncated]\n"
+)
+
+var errJobNotFound = errors.New("job not found")
+
+// runningJob 执行中的任务，输出先写入内存，按间隔持久化
+type runningJob struct {
+	job         models.Job
+	savedAt     time.Time
+	subscribers map[chan struct{}]struct{}
+}
+
+// JobManager 在后台执行集群搭建任务。任务不随客户端断开而取消，输出和状态持久化到数据库，
+// 结束后保留 JobRetention 时长
+type JobManager struct {
+	h *Handler
+
+	mu      sync.Mutex
+	running map[string]*runningJob // 任务ID -> 执行中的任务
+}
+
+func NewJobManager(h *Handler) *JobManager {
+	return &JobManager{h: h, running: make(map[string]*runningJob)}
+}
+
+// Run 将服务重启前未完成的任务标记为失败，之后定期清理过期任务，直到 ctx 结束
+func (m *JobManager) Run(ctx context.Context) {
+	m.recover()
+	ticker := time.NewTicker(jobCleanupInterval)
+	defer ticker.Stop()
+	for {
+		m.expire()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recover 执行中的任务随服务重启丢失，标记为失败
+func (m *JobManager) recover() {
+	jobs, err := m.h.DB.ListJobs()
+	if err != nil {
+		log.Printf("Failed to list jobs: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.Finished() {
+			continue
+		}
+		job.Status = models.JobFailed
+		job.Error = "interrupted by server restart"
+		job.FinishedAt = time.Now()
+		if err := m.h.DB.SaveJob(job); err != nil {
+			log.Printf("Failed to save job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// expire 删除结束超过保留时长的任务。已结束的任务不在 running 中，也不会再被修改，
+// 因此遍历和删除不持有锁，避免阻塞执行中任务的输出
+func (m *JobManager) expire() {
+	jobs, err := m.h.DB.ListJobs()
+	if err != nil {
+		log.Printf("Failed to list jobs: %v", err)
+		return
+	}
+	cutoff := time.Now().Add(-m.h.cfg().JobRetention)
+	for _, job := range jobs {
+		if job.Finished() && job.FinishedAt.Before(cutoff) {
+			if err := m.h.DB.DeleteJob(job.ID); err != nil {
+				log.Printf("Failed to delete job %s: %v", job.ID, err)
+			}
+		}
+	}
+}
+
+// Start 为用户创建任务并在用户容器所在的主机 dm 上后台执行 req。用户有执行中的搭建操作时返回 errSetupBusy。
+// onFailure 不为 nil 时在任务失败后调用，用于归还任务占用的配额
+func (m *JobManager) Start(dm *docker.DockerManager, user *models.User, step string, req docker.ChainRequest, onFailure func()) (*models.Job, error) {
+	release, err := m.h.busy.acquire(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	job := models.Job{
+		ID:        hex.EncodeToString(b),
+		UserID:    user.ID,
+		Step:      step,
+		Status:    models.JobRunning,
+		CreatedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	if err := m.h.DB.SaveJob(&job); err != nil {
+		m.mu.Unlock()
+		release()
+		return nil, err
+	}
+	m.running[job.ID] = &runningJob{job: job, savedAt: time.Now(), subscribers: make(map[chan struct{}]struct{})}
+	m.mu.Unlock()
+
+	containerID := user.ContainerID
+	go func() {
+		defer release()
+		// 不使用请求的 ctx，任务在客户端断开后继续执行
+		ctx, cancel := m.h.chainContext(context.Background(), req.Path)
+		defer cancel()
+		statusCode, err := dm.StreamChainRequest(ctx, containerID, req, jobWriter{m: m, id: job.ID})
+		if m.finish(job.ID, statusCode, err) == models.JobFailed && onFailure != nil {
+			onFailure()
+		}
+	}()
+	return &job, nil
+}
+
+// jobWriter 将 chain-proxy 的输出追加到任务
+type jobWriter struct {
+	m  *JobManager
+	id string
+}
+
+func (w jobWriter) Write(p []byte) (int, error) {
+	w.m.appendOutput(w.id, string(p))
+	return len(p), nil
+}
+
+func (m *JobManager) appendOutput(id, output string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	running, ok := m.running[id]
+	if !ok {
+		return
+	}
+
+	job := &running.job
+	// 超出上限的输出被丢弃，已有输出不截断，订阅者可按偏移量读取新增部分
+	if max := m.h.cfg().JobMaxOutput; len(job.Output)+len(output) > max {
+		if len(job.Output) >= max {
+			return
+		}
+		output = output[:max-len(job.Output)] + jobTruncatedNote
+	}
+	job.Output += output
+	if time.Since(running.savedAt) >= jobSaveInterval {
+		m.save(running)
+	}
+	m.notify(running)
+}
+
+// finish 记录任务结果并返回最终状态，chain-proxy 返回非 2xx 时任务失败
+func (m *JobManager) finish(id string, statusCode int, err error) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	running, ok := m.running[id]
+	if !ok {
+		return ""
+	}
+
+	job := &running.job
+	job.StatusCode = statusCode
+	job.FinishedAt = time.Now()
+	switch {
+	case err != nil:
+		job.Status = models.JobFailed
+		job.Error = err.Error()
+	case statusCode < 200 || statusCode >= 300:
+		job.Status = models.JobFailed
+		job.Error = http.StatusText(statusCode)
+	default:
+		job.Status = models.JobSucceeded
+	}
+	m.save(running)
+	m.notify(running)
+	delete(m.running, id)
+	return job.Status
+}
+
+// save 持久化任务，调用方需持有锁
+func (m *JobManager) save(running *runningJob) {
+	job := running.job
+	if err := m.h.DB.SaveJob(&job); err != nil {
+		log.Printf("Failed to save job %s: %v", job.ID, err)
+		return
+	}
+	running.savedAt = time.Now()
+}
+
+// notify 通知订阅者任务有新输出或状态变化，调用方需持有锁
+func (m *JobManager) notify(running *runningJob) {
+	for ch := range running.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Get 返回任务当前状态，执行中的任务包含尚未持久化的输出
+func (m *JobManager) Get(id string) (models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if running, ok := m.running[id]; ok {
+		return running.job, nil
+	}
+	job, err := m.h.DB.GetJob(id)
+	if err != nil {
+		return models.Job{}, errJobNotFound
+	}
+	return *job, nil
+}
+
+// Subscribe 订阅任务的输出和状态变化通知，调用返回的函数取消订阅。任务已结束时不会收到通知
+func (m *JobManager) Subscribe(id string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	running, ok := m.running[id]
+	if !ok {
+		return ch, func() {}
+	}
+	running.subscribers[ch] = struct{}{}
+	return ch, func() {
+		m.mu.Lock()
+		delete(running.subscribers, ch)
+		m.mu.Unlock()
+	}
+}
+
+// userJob 返回属于当前用户的任务
+func (h *Handler) userJob(c *gin.Context) (models.Job, bool) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return models.Job{}, false
+	}
+	var job models.Job
+	if h.Jobs != nil {
+		job, err = h.Jobs.Get(c.Param("id"))
+	}
+	// 不区分不存在和属于其他用户，避免泄露任务ID
+	if h.Jobs == nil || err != nil || job.UserID != userID {
+		respondError(c, &apiError{Status: http.StatusNotFound, Code: ErrCodeNotFound, Message: errJobNotFound.Error()})
+		return models.Job{}, false
+	}
+	return job, true
+}
+
+// GetJob 查询当前用户的搭建任务，包含目前为止的输出
+func (h *Handler) GetJob(c *gin.Context) {
+	job, ok := h.userJob(c)
+	if !ok {
+		return
+	}
+	respondData(c, http.StatusOK, job)
+}
+
+// StreamJob 以 Server-Sent Events 推送任务输出：先发送已有输出，之后为新增输出（output 事件），
+// 任务结束时发送最终状态（status 事件，不含输出）并关闭连接
+func (h *Handler) StreamJob(c *gin.Context) {
+	job, ok := h.userJob(c)
+	if !ok {
+		return
+	}
+
+	// 先订阅再读取状态，避免错过两者之间的通知
+	updates, unsubscribe := h.Jobs.Subscribe(job.ID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	offset := 0
+	for {
+		job, err := h.Jobs.Get(job.ID)
+		if err != nil {
+			return
+		}
+		if len(job.Output) > offset {
+			c.SSEvent("output", job.Output[offset:])
+			offset = len(job.Output)
+		}
+		if job.Finished() {
+			job.Output = ""
+			c.SSEvent("status", job)
+			c.Writer.Flush()
+			return
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-updates:
+		}
+	}
+}
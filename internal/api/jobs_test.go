@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/database"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncSetupJob(t *testing.T) {
+	gate := make(chan struct{})
+	platform, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("compiling\n"))
+		w.(http.Flusher).Flush()
+		<-gate
+		_, _ = w.Write([]byte("done\n"))
+	}))
+	handler.Jobs = NewJobManager(handler)
+
+	code, _, body := doChainRequest(t, platform, "POST", "/api/build-blockchain?async=true", "", nil)
+	assert.Equal(t, http.StatusAccepted, code)
+	var accepted struct {
+		Data struct {
+			JobID string `json:"jobID"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(body), &accepted))
+	id := accepted.Data.JobID
+	assert.NotEmpty(t, id)
+
+	// 执行中即可看到已有输出
+	assert.Eventually(t, func() bool {
+		job, err := handler.Jobs.Get(id)
+		return err == nil && job.Output == "compiling\n"
+	}, time.Second, 10*time.Millisecond)
+
+	// 同一用户同时只能有一个任务
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/build-blockchain?async=true", "", nil)
+	assert.Equal(t, http.StatusConflict, code)
+	// 同步搭建步骤、流水线和删除容器与任务共用同一标记
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/cluster/stop", "", nil)
+	assert.Equal(t, http.StatusConflict, code)
+	_, err := handler.removeContainer(context.Background(), "student")
+	assert.Equal(t, http.StatusConflict, toAPIError(err).Status)
+	_, err = handler.createContainer(context.Background(), "student", CreateModeRecreate)
+	assert.Equal(t, http.StatusConflict, toAPIError(err).Status)
+	// 通过 exec 和透传代理调用搭建步骤同样冲突
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/container/exec", `{"cmd":["mis","/setup/build/chain"]}`, nil)
+	assert.Equal(t, http.StatusConflict, code)
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/chain/setup/build/chain", "", nil)
+	assert.Equal(t, http.StatusConflict, code)
+
+	time.AfterFunc(50*time.Millisecond, func() { close(gate) })
+	code, header, stream := doChainRequest(t, platform, "GET", "/api/jobs/"+id+"/stream", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "text/event-stream", header.Get("Content-Type"))
+	assert.Contains(t, stream, "event:output\ndata:compiling")
+	assert.Contains(t, stream, "data:done")
+	assert.Contains(t, stream, `"status":"succeeded"`)
+
+	// 结束后从数据库读取
+	code, _, body = doChainRequest(t, platform, "GET", "/api/jobs/"+id, "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	var result struct {
+		Data models.Job `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(body), &result))
+	assert.Equal(t, models.JobSucceeded, result.Data.Status)
+	assert.Equal(t, "compiling\ndone\n", result.Data.Output)
+	assert.Equal(t, http.StatusOK, result.Data.StatusCode)
+}
+
+func TestGetJobOfOtherUser(t *testing.T) {
+	platform, handler := setupChainProxy(t, http.NotFoundHandler())
+	handler.Jobs = NewJobManager(handler)
+	handler.DB.SaveJob(&models.Job{ID: "j1", UserID: "other", Status: models.JobSucceeded})
+
+	for _, path := range []string{"/api/jobs/j1", "/api/jobs/j1/stream", "/api/jobs/missing"} {
+		code, _, _ := doChainRequest(t, platform, "GET", path, "", nil)
+		assert.Equal(t, http.StatusNotFound, code, path)
+	}
+}
+
+func TestJobFailsOnUpstreamError(t *testing.T) {
+	platform, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "cluster not created", http.StatusInternalServerError)
+	}))
+	handler.Jobs = NewJobManager(handler)
+
+	code, _, body := doChainRequest(t, platform, "POST", "/api/build-blockchain?async=true", "", nil)
+	assert.Equal(t, http.StatusAccepted, code)
+	var accepted struct {
+		Data struct {
+			JobID string `json:"jobID"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(body), &accepted))
+	id := accepted.Data.JobID
+
+	assert.Eventually(t, func() bool {
+		job, err := handler.Jobs.Get(id)
+		return err == nil && job.Finished()
+	}, time.Second, 10*time.Millisecond)
+	job, _ := handler.Jobs.Get(id)
+	assert.Equal(t, models.JobFailed, job.Status)
+	assert.Equal(t, http.StatusInternalServerError, job.StatusCode)
+	assert.Contains(t, job.Output, "cluster not created")
+}
+
+func TestJobManagerRecoverAndExpire(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveJob(&models.Job{ID: "interrupted", Status: models.JobRunning})
+	mockDB.SaveJob(&models.Job{ID: "old", Status: models.JobSucceeded, FinishedAt: time.Now().Add(-48 * time.Hour)})
+	mockDB.SaveJob(&models.Job{ID: "recent", Status: models.JobFailed, FinishedAt: time.Now()})
+
+	m := NewJobManager(handler)
+	m.recover()
+	m.expire()
+
+	assert.Equal(t, models.JobFailed, mockDB.Jobs["interrupted"].Status)
+	assert.NotContains(t, mockDB.Jobs, "old")
+	assert.Contains(t, mockDB.Jobs, "recent")
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"sync"
+)
+
+var errSetupBusy = errors.New("a setup job, pipeline or container operation is already running")
+
+// userLocks 为每个用户维护一把互斥锁，保证同一用户的容器操作与用户记录的读写串行执行
+type userLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock 获取指定用户的锁，返回解锁函数
+func (l *userLocks) lock(userID string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	m, ok := l.locks[userID]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[userID] = m
+	}
+	l.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
+
+// busyUsers 标记正在执行搭建操作的用户。后台任务、流水线和同步搭建步骤共用，同一用户同时只能执行一个，
+// 删除容器时同样需要标记，避免删除正在搭建的容器。搭建操作耗时较长，因此不等待而是直接返回冲突
+type busyUsers struct {
+	mu    sync.Mutex
+	users map[string]bool
+}
+
+// acquire 标记用户为忙碌，返回释放函数；用户已忙碌时返回 errSetupBusy
+func (b *busyUsers) acquire(userID string) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.users[userID] {
+		return nil, errSetupBusy
+	}
+	if b.users == nil {
+		b.users = make(map[string]bool)
+	}
+	b.users[userID] = true
+	return func() {
+		b.mu.Lock()
+		delete(b.users, userID)
+		b.mu.Unlock()
+	}, nil
+}
+
+// isBusy 判断用户是否正在执行搭建操作
+func (b *busyUsers) isBusy(userID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.users[userID]
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// sseLogWriter 将写入的日志按行作为 Server-Sent Events 发送，事件名区分 stdout 和 stderr
+type sseLogWriter struct {
+	c     *gin.Context
+	event string
+}
+
+func (w *sseLogWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.c.SSEvent(w.event, line)
+	}
+	w.c.Writer.Flush()
+	return len(p), nil
+}
+
+// ContainerLogs 以 Server-Sent Events 流式返回当前用户容器的日志，
+// 支持 tail、since、until 和 follow 查询参数
+func (h *Handler) ContainerLogs(c *gin.Context) {
+	opts := docker.LogOptions{
+		Tail:   c.DefaultQuery("tail", "100"),
+		Since:  c.Query("since"),
+		Until:  c.Query("until"),
+		Follow: c.Query("follow") == "true",
+	}
+	if opts.Tail != "all" {
+		if n, err := strconv.Atoi(opts.Tail); err != nil || n < 0 {
+			respondError(c, newAPIError(http.StatusBadRequest, "Invalid tail, must be a non-negative number or all"))
+			return
+		}
+	}
+
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// 客户端断开时 ctx 结束，follow 模式的日志流随之关闭
+	logs, err := dm.ContainerLogs(c.Request.Context(), user.ContainerID, opts)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to read container logs: "+err.Error()))
+		return
+	}
+	defer logs.Close()
+
+	streamLogs(c, logs)
+}
+
+// streamLogs 分离多路复用的日志流并逐行发送，结束时发送 end 事件
+func streamLogs(c *gin.Context, logs io.Reader) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	stdout := &sseLogWriter{c: c, event: "stdout"}
+	stderr := &sseLogWriter{c: c, event: "stderr"}
+	// 响应头已发送，错误只能作为事件返回
+	if _, err := stdcopy.StdCopy(stdout, stderr, logs); err != nil && c.Request.Context().Err() == nil {
+		c.SSEvent("error", err.Error())
+	}
+	c.SSEvent("end", "")
+	c.Writer.Flush()
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamLogsDemultiplexes(t *testing.T) {
+	var logs bytes.Buffer
+	_, _ = stdcopy.NewStdWriter(&logs, stdcopy.Stdout).Write([]byte("node 1 started\nnode 2 started\n"))
+	_, _ = stdcopy.NewStdWriter(&logs, stdcopy.Stderr).Write([]byte("consensus timeout\n"))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/container/logs", nil)
+	streamLogs(c, &logs)
+
+	body := w.Body.String()
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Contains(t, body, "event:stdout\ndata:node 1 started\n")
+	assert.Contains(t, body, "event:stdout\ndata:node 2 started\n")
+	assert.Contains(t, body, "event:stderr\ndata:consensus timeout\n")
+	assert.Contains(t, body, "event:end\n")
+}
+
+func TestContainerLogsInvalidTail(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.Default()
+	router.GET("/container/logs", handler.ContainerLogs)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/container/logs?tail=-1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":{"code":"`+ErrCodeBadRequest+`"`)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// operationFunc 容器操作的具体实现，返回成功时的数据或错误
+type operationFunc func(ctx context.Context, userID string) (interface{}, error)
+
+// operationResult 队列中操作的执行结果，即同步调用时的响应
+type operationResult struct {
+	StatusCode int      `json:"statusCode"`
+	Body       envelope `json:"body"`
+}
+
+// newOperationResult 将操作的返回值转换为 {data, error} 格式的响应
+func newOperationResult(data interface{}, err error) *operationResult {
+	if err != nil {
+		apiErr := toAPIError(err)
+		return &operationResult{StatusCode: apiErr.Status, Body: envelope{Error: apiErr}}
+	}
+	return &operationResult{StatusCode: http.StatusOK, Body: envelope{Data: data}}
+}
+
+// runOperation 将容器操作提交到 Docker 操作队列。请求带 ?async=true 时立即返回 202 和操作ID，
+// 否则等待操作完成后返回其结果；未配置队列时直接在请求中执行
+func (h *Handler) runOperation(c *gin.Context, opType string, fn operationFunc) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if h.Queue == nil {
+		result := newOperationResult(fn(c.Request.Context(), userID))
+		c.JSON(result.StatusCode, result.Body)
+		return
+	}
+
+	id := h.Queue.Submit(userID, opType, func(ctx context.Context) (interface{}, error) {
+		result := newOperationResult(fn(ctx, userID))
+		if result.Body.Error != nil {
+			return result, result.Body.Error
+		}
+		return result, nil
+	})
+
+	if c.Query("async") == "true" {
+		respondData(c, http.StatusAccepted, gin.H{"operationID": id})
+		return
+	}
+
+	op, err := h.Queue.Wait(c.Request.Context(), id)
+	if err != nil {
+		// 客户端断开或超时，操作仍在队列中继续执行，可通过操作ID查询
+		respondData(c, http.StatusAccepted, gin.H{"operationID": id})
+		return
+	}
+	result := op.Result.(*operationResult)
+	c.JSON(result.StatusCode, result.Body)
+}
+
+// GetOperation 查询当前用户提交的容器操作状态
+func (h *Handler) GetOperation(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var op docker.Operation
+	found := false
+	if h.Queue != nil {
+		op, found = h.Queue.Get(c.Param("id"))
+	}
+	// 不区分不存在和属于其他用户，避免泄露操作ID
+	if !found || op.UserID != userID {
+		respondError(c, &apiError{Status: http.StatusNotFound, Code: ErrCodeNotFound, Message: docker.ErrOperationNotFound.Error()})
+		return
+	}
+	respondData(c, http.StatusOK, op)
+}
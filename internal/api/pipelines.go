@@ -0,0 +1,377 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pipelineOutputLimit 每个步骤保存的输出上限（字节）
+const pipelineOutputLimit = 64 << 10
+
+var (
+	errRunNotFound      = errors.New("pipeline run not found")
+	errRunNotResumable  = errors.New("only failed pipeline runs can be resumed")
+	errPipelineChanged  = errors.New("pipeline definition changed since the run started")
+	errPipelineNotFound = errors.New("pipeline not found")
+)
+
+// PipelineRunner 在后台按顺序执行流水线步骤。每步完成后保存断点，失败或服务重启后可从断点继续
+type PipelineRunner struct {
+	h *Handler
+
+	mu sync.Mutex
+}
+
+func NewPipelineRunner(h *Handler) *PipelineRunner {
+	return &PipelineRunner{h: h}
+}
+
+// Recover 将服务重启前中断的执行标记为失败，之后可以继续执行
+func (r *PipelineRunner) Recover() {
+	runs, err := r.h.DB.ListPipelineRuns()
+	if err != nil {
+		log.Printf("Failed to list pipeline runs: %v", err)
+		return
+	}
+	for _, run := range runs {
+		if run.Status != models.PipelineRunning {
+			continue
+		}
+		run.Status = models.PipelineFailed
+		run.Error = "interrupted by server restart"
+		run.UpdatedAt = time.Now()
+		if err := r.h.DB.SavePipelineRun(run); err != nil {
+			log.Printf("Failed to save pipeline run %s: %v", run.ID, err)
+		}
+	}
+}
+
+// Start 为用户创建流水线执行并在后台运行
+func (r *PipelineRunner) Start(user *models.User, pipeline models.Pipeline) (*models.PipelineRun, error) {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	run := &models.PipelineRun{
+		ID:        hex.EncodeToString(b),
+		UserID:    user.ID,
+		Pipeline:  pipeline.Name,
+		Status:    models.PipelineRunning,
+		Steps:     make([]models.PipelineStepResult, len(pipeline.Steps)),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	for i, step := range pipeline.Steps {
+		run.Steps[i].Name = step.Name
+	}
+	return run, r.launch(user, pipeline, run)
+}
+
+// Resumable 检查失败的执行能否从断点继续，返回当前的流水线定义和执行记录的副本
+func (r *PipelineRunner) Resumable(user *models.User, runID string) (models.Pipeline, *models.PipelineRun, error) {
+	run, err := r.Get(runID)
+	if err != nil || run.UserID != user.ID {
+		return models.Pipeline{}, nil, errRunNotFound
+	}
+	if run.Status != models.PipelineFailed {
+		return models.Pipeline{}, nil, errRunNotResumable
+	}
+	pipeline, ok := r.h.cfg().Pipeline(run.Pipeline)
+	if !ok {
+		return models.Pipeline{}, nil, errPipelineNotFound
+	}
+	if len(pipeline.Steps) != len(run.Steps) {
+		return models.Pipeline{}, nil, errPipelineChanged
+	}
+	for i, step := range pipeline.Steps {
+		if run.Steps[i].Name != step.Name {
+			return models.Pipeline{}, nil, errPipelineChanged
+		}
+	}
+	return pipeline, run, nil
+}
+
+// Resume 从 Resumable 返回的执行的断点继续，已成功的步骤不再执行
+func (r *PipelineRunner) Resume(user *models.User, pipeline models.Pipeline, run *models.PipelineRun) error {
+	run.Status = models.PipelineRunning
+	run.Error = ""
+	run.UpdatedAt = time.Now()
+	return r.launch(user, pipeline, run)
+}
+
+// buildsChain 判断步骤中是否有编译区块链的步骤
+func buildsChain(steps []models.PipelineStep) bool {
+	for _, step := range steps {
+		if isBuildPath(step.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// launch 保存执行记录并启动后台执行。用户有执行中的搭建操作时返回 errSetupBusy
+func (r *PipelineRunner) launch(user *models.User, pipeline models.Pipeline, run *models.PipelineRun) error {
+	dm, err := r.h.dockerFor(user)
+	if err != nil {
+		return err
+	}
+	release, err := r.h.busy.acquire(user.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.save(run); err != nil {
+		release()
+		return err
+	}
+	owner := *user
+	go func() {
+		defer release()
+		r.execute(dm, &owner, pipeline, snapshotRun(run))
+	}()
+	return nil
+}
+
+// execute 从 run.NextStep 开始执行，不使用请求的 ctx，客户端断开后继续执行。
+// 每个步骤执行前检查用户的 exec 策略，不允许的步骤直接失败
+func (r *PipelineRunner) execute(dm *docker.DockerManager, user *models.User, pipeline models.Pipeline, run *models.PipelineRun) {
+	ctx := context.Background()
+
+	for i := run.NextStep; i < len(pipeline.Steps); i++ {
+		step := pipeline.Steps[i]
+		var result models.PipelineStepResult
+		if err := r.h.checkExecPolicy(user, stepRequest(step)); err != nil {
+			result = models.PipelineStepResult{Name: step.Name, Status: models.PipelineFailed, Error: err.Error(), FinishedAt: time.Now()}
+		} else {
+			result = r.runStep(ctx, dm, user.ContainerID, step)
+		}
+		run.Steps[i] = result
+		run.UpdatedAt = time.Now()
+		if result.Status == models.PipelineFailed {
+			run.Status = models.PipelineFailed
+			run.Error = "step " + result.Name + " failed: " + result.Error
+			r.saveLogged(run)
+			return
+		}
+		run.NextStep = i + 1
+		r.saveLogged(run)
+	}
+	run.Status = models.PipelineSucceeded
+	run.UpdatedAt = time.Now()
+	r.saveLogged(run)
+}
+
+// stepRequest 步骤对应的 chain-proxy 请求，未指定方法时为 POST
+func stepRequest(step models.PipelineStep) docker.ChainRequest {
+	method := step.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	return docker.ChainRequest{Method: method, Path: step.Path, Body: []byte(step.Body)}
+}
+
+// runStep 执行一个步骤，失败（请求出错或结果不符合期望）时按步骤配置重试
+func (r *PipelineRunner) runStep(ctx context.Context, dm *docker.DockerManager, containerID string, step models.PipelineStep) models.PipelineStepResult {
+	req := stepRequest(step)
+
+	result := models.PipelineStepResult{Name: step.Name}
+	for attempt := 0; attempt <= step.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(step.RetryDelay)
+		}
+		result.Attempts = attempt + 1
+
+		stepCtx, cancel := r.h.chainContext(ctx, step.Path)
+		resp, err := dm.DoChainRequest(stepCtx, containerID, req)
+		cancel()
+		if err == nil {
+			output := string(resp.Body)
+			result.StatusCode = resp.StatusCode
+			result.Output = output
+			if len(output) > pipelineOutputLimit {
+				result.Output = output[:pipelineOutputLimit]
+			}
+			err = step.Check(resp.StatusCode, output)
+		}
+		if err == nil {
+			result.Status = models.PipelineSucceeded
+			result.Error = ""
+			break
+		}
+		result.Status = models.PipelineFailed
+		result.Error = err.Error()
+	}
+	result.FinishedAt = time.Now()
+	return result
+}
+
+// snapshotRun 复制执行记录，后台执行修改自己的副本，保存和读取的也是副本
+func snapshotRun(run *models.PipelineRun) *models.PipelineRun {
+	copied := *run
+	copied.Steps = append([]models.PipelineStepResult(nil), run.Steps...)
+	return &copied
+}
+
+func (r *PipelineRunner) save(run *models.PipelineRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.h.DB.SavePipelineRun(snapshotRun(run))
+}
+
+func (r *PipelineRunner) saveLogged(run *models.PipelineRun) {
+	if err := r.save(run); err != nil {
+		log.Printf("Failed to save pipeline run %s: %v", run.ID, err)
+	}
+}
+
+// Get 返回执行记录的副本
+func (r *PipelineRunner) Get(runID string) (*models.PipelineRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, err := r.h.DB.GetPipelineRun(runID)
+	if err != nil {
+		return nil, errRunNotFound
+	}
+	return snapshotRun(run), nil
+}
+
+// respondPipelineError 将流水线错误转换为带错误码的平台错误写入响应
+func respondPipelineError(c *gin.Context, err error) {
+	var httpErr *httpError
+	switch {
+	case errors.As(err, &httpErr):
+		respondError(c, err)
+	case errors.Is(err, errRunNotFound), errors.Is(err, errPipelineNotFound):
+		respondError(c, &apiError{Status: http.StatusNotFound, Code: ErrCodeNotFound, Message: err.Error()})
+	case errors.Is(err, errSetupBusy), errors.Is(err, errRunNotResumable), errors.Is(err, errPipelineChanged):
+		respondError(c, &apiError{Status: http.StatusConflict, Code: ErrCodeConflict, Message: err.Error()})
+	default:
+		respondError(c, internalError("Failed to start pipeline"))
+	}
+}
+
+// pipelineAccepted 流水线开始执行或继续执行时返回的数据
+type pipelineAccepted struct {
+	RunID    string `json:"runID"`
+	Pipeline string `json:"pipeline"`
+	NextStep int    `json:"nextStep"`
+}
+
+// startPipeline 为 user 运行名为 :name 的流水线
+func (h *Handler) startPipeline(c *gin.Context, user *models.User) {
+	if h.Pipelines == nil {
+		respondError(c, &apiError{Status: http.StatusServiceUnavailable, Code: ErrCodeUnavailable, Message: "Pipeline runner is not running"})
+		return
+	}
+	pipeline, ok := h.cfg().Pipeline(c.Param("name"))
+	if !ok {
+		respondPipelineError(c, errPipelineNotFound)
+		return
+	}
+	if user.ContainerID == "" {
+		respondError(c, noContainerError())
+		return
+	}
+
+	// 包含编译步骤的流水线占用一次编译配额
+	builds := buildsChain(pipeline.Steps)
+	if builds && !h.takeQuota(c, user, QuotaBuildChain) {
+		return
+	}
+
+	run, err := h.Pipelines.Start(user, pipeline)
+	if err != nil {
+		if builds {
+			h.refundQuota(c, user, QuotaBuildChain)
+		}
+		respondPipelineError(c, err)
+		return
+	}
+	respondData(c, http.StatusAccepted, pipelineAccepted{RunID: run.ID, Pipeline: pipeline.Name})
+}
+
+// ListPipelines 返回可运行的流水线定义
+func (h *Handler) ListPipelines(c *gin.Context) {
+	respondData(c, http.StatusOK, h.cfg().Pipelines)
+}
+
+// RunPipeline 对当前用户的容器运行流水线，立即返回执行ID
+func (h *Handler) RunPipeline(c *gin.Context) {
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.startPipeline(c, user)
+}
+
+// AdminRunPipeline 管理员为指定学生运行流水线
+func (h *Handler) AdminRunPipeline(c *gin.Context) {
+	user, err := h.DB.GetUser(c.Param("id"))
+	if err != nil {
+		respondError(c, &apiError{Status: http.StatusNotFound, Code: ErrCodeNotFound, Message: "User not found"})
+		return
+	}
+	h.startPipeline(c, user)
+}
+
+// GetPipelineRun 查询当前用户的流水线执行，管理员可查询任意学生的执行
+func (h *Handler) GetPipelineRun(c *gin.Context) {
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if h.Pipelines == nil {
+		respondPipelineError(c, errRunNotFound)
+		return
+	}
+	run, err := h.Pipelines.Get(c.Param("id"))
+	// 不区分不存在和属于其他用户，避免泄露执行ID
+	if err != nil || (run.UserID != user.ID && !user.IsAdmin()) {
+		respondPipelineError(c, errRunNotFound)
+		return
+	}
+	respondData(c, http.StatusOK, run)
+}
+
+// ResumePipelineRun 从失败执行的断点继续
+func (h *Handler) ResumePipelineRun(c *gin.Context) {
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if h.Pipelines == nil {
+		respondPipelineError(c, errRunNotFound)
+		return
+	}
+	if user.ContainerID == "" {
+		respondError(c, noContainerError())
+		return
+	}
+	pipeline, run, err := h.Pipelines.Resumable(user, c.Param("id"))
+	if err != nil {
+		respondPipelineError(c, err)
+		return
+	}
+	// 剩余步骤中有编译步骤时占用一次编译配额
+	builds := buildsChain(pipeline.Steps[run.NextStep:])
+	if builds && !h.takeQuota(c, user, QuotaBuildChain) {
+		return
+	}
+	if err := h.Pipelines.Resume(user, pipeline, run); err != nil {
+		if builds {
+			h.refundQuota(c, user, QuotaBuildChain)
+		}
+		respondPipelineError(c, err)
+		return
+	}
+	respondData(c, http.StatusAccepted, pipelineAccepted{RunID: run.ID, Pipeline: run.Pipeline, NextStep: run.NextStep})
+}
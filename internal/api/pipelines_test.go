@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineRetryAndResume(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	broken := true
+	platform, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[r.Method+" "+r.URL.Path]++
+		switch {
+		case r.URL.Path == "/setup/build/chain" && broken:
+			http.Error(w, "go: build failed", http.StatusInternalServerError)
+		case r.URL.Path == "/proxy/-1/consensus":
+			_, _ = w.Write([]byte(`{"height":3}`))
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	handler.Pipelines = NewPipelineRunner(handler)
+	handler.Config.Pipelines = []models.Pipeline{{
+		Name: "mini",
+		Steps: []models.PipelineStep{
+			{Name: "genesis-addrs", Path: "/setup/genesis/addrs"},
+			{Name: "build-chain", Path: "/setup/build/chain", Retries: 1, RetryDelay: 10 * time.Millisecond},
+			{Name: "liveness", Method: "GET", Path: "/proxy/-1/consensus", ExpectOutput: "height"},
+		},
+	}}
+
+	code, _, body := doChainRequest(t, platform, "POST", "/api/pipelines/mini/run", "", nil)
+	assert.Equal(t, http.StatusAccepted, code)
+	var accepted struct {
+		Data pipelineAccepted `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(body), &accepted))
+
+	run := waitPipelineRun(t, handler, accepted.Data.RunID)
+	assert.Equal(t, models.PipelineFailed, run.Status)
+	assert.Equal(t, 1, run.NextStep)
+	assert.Equal(t, models.PipelineSucceeded, run.Steps[0].Status)
+	assert.Equal(t, 2, run.Steps[1].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, run.Steps[1].StatusCode)
+	assert.Contains(t, run.Error, "build-chain")
+
+	// 修复后从断点继续，已成功的步骤不再执行
+	mu.Lock()
+	broken = false
+	mu.Unlock()
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/pipeline-runs/"+accepted.Data.RunID+"/resume", "", nil)
+	assert.Equal(t, http.StatusAccepted, code)
+
+	run = waitPipelineRun(t, handler, accepted.Data.RunID)
+	assert.Equal(t, models.PipelineSucceeded, run.Status)
+	assert.Equal(t, 3, run.NextStep)
+	mu.Lock()
+	assert.Equal(t, 1, calls["POST /setup/genesis/addrs"])
+	assert.Equal(t, 3, calls["POST /setup/build/chain"])
+	assert.Equal(t, 1, calls["GET /proxy/-1/consensus"])
+	mu.Unlock()
+
+	code, _, body = doChainRequest(t, platform, "GET", "/api/pipeline-runs/"+accepted.Data.RunID, "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"status":"succeeded"`)
+
+	// 已成功的执行不能继续，未知流水线返回 404
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/pipeline-runs/"+accepted.Data.RunID+"/resume", "", nil)
+	assert.Equal(t, http.StatusConflict, code)
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/pipelines/missing/run", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestPipelineRunOfOtherUser(t *testing.T) {
+	platform, handler := setupChainProxy(t, http.NotFoundHandler())
+	handler.Pipelines = NewPipelineRunner(handler)
+	handler.DB.SavePipelineRun(&models.PipelineRun{ID: "r1", UserID: "other", Pipeline: "lab-cluster", Status: models.PipelineFailed})
+
+	code, _, _ := doChainRequest(t, platform, "GET", "/api/pipeline-runs/r1", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _, _ = doChainRequest(t, platform, "POST", "/api/pipeline-runs/r1/resume", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestPipelineRunnerRecover(t *testing.T) {
+	handler := setupTestHandler()
+	handler.DB.SavePipelineRun(&models.PipelineRun{ID: "r1", Status: models.PipelineRunning, NextStep: 4})
+
+	NewPipelineRunner(handler).Recover()
+	run, _ := handler.DB.GetPipelineRun("r1")
+	assert.Equal(t, models.PipelineFailed, run.Status)
+	assert.Equal(t, 4, run.NextStep)
+}
+
+func waitPipelineRun(t *testing.T, handler *Handler, id string) *models.PipelineRun {
+	var run *models.PipelineRun
+	assert.Eventually(t, func() bool {
+		var err error
+		run, err = handler.Pipelines.Get(id)
+		return err == nil && run.Status != models.PipelineRunning
+	}, 2*time.Second, 10*time.Millisecond)
+	// 后台执行在保存最终状态后才释放用户，等待释放避免下一次启动冲突
+	assert.Eventually(t, func() bool {
+		return !handler.busy.isBusy(run.UserID)
+	}, time.Second, 10*time.Millisecond)
+	return run
+}
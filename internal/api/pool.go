@@ -0,0 +1,270 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 预热池容器的占位用户ID前缀。容器分配给学生后，其网络、数据卷和端口一并转归学生所有
+const poolUserPrefix = "pool-"
+
+// randomID 生成随机的十六进制ID
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// poolTarget 某个镜像的容器池目标大小
+type poolTarget struct {
+	image    *models.Image
+	size     int
+	prestart bool
+}
+
+// ContainerPool 按镜像分组的预创建容器池。学生首次创建容器时优先从池中分配，
+// 后台按实验课排期补充或缩减
+type ContainerPool struct {
+	h *Handler
+
+	mu   sync.Mutex
+	idle map[string][]*models.User // 镜像引用 -> 空闲容器的占位用户
+}
+
+func NewContainerPool(h *Handler) *ContainerPool {
+	return &ContainerPool{
+		h:    h,
+		idle: make(map[string][]*models.User),
+	}
+}
+
+// Run 清理上次运行遗留的空闲池容器，然后定期调整容器池，直到 ctx 结束
+func (p *ContainerPool) Run(ctx context.Context) {
+	p.cleanup(ctx)
+
+	ticker := time.NewTicker(p.h.cfg().PoolRefillInterval)
+	defer ticker.Stop()
+	for {
+		p.refill(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Take 取出一个指定镜像的空闲容器，没有时返回 nil
+func (p *ContainerPool) Take(imageRef string) *models.User {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idle := p.idle[imageRef]
+	if len(idle) == 0 {
+		return nil
+	}
+	owner := idle[0]
+	p.idle[imageRef] = idle[1:]
+	return owner
+}
+
+// count 返回指定镜像当前的空闲容器数量
+func (p *ContainerPool) count(imageRef string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle[imageRef])
+}
+
+// Status 返回各镜像当前的空闲容器数量
+func (p *ContainerPool) Status() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := make(map[string]int)
+	for ref, idle := range p.idle {
+		status[ref] = len(idle)
+	}
+	return status
+}
+
+// targets 根据默认配置和当前（含提前预热时间）进行中的实验课计算各镜像的目标池大小
+func (p *ContainerPool) targets(now time.Time) (map[string]*poolTarget, error) {
+	cfg := p.h.cfg()
+	targets := make(map[string]*poolTarget)
+	if cfg.PoolSize > 0 {
+		image := &models.Image{Name: cfg.DefaultImage}
+		targets[image.Reference()] = &poolTarget{image: image, size: cfg.PoolSize, prestart: cfg.PoolPrestart}
+	}
+
+	sessions, err := p.h.DB.ListLabSessions()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		if !session.ActiveAt(now, cfg.PoolLeadTime) {
+			continue
+		}
+		image, err := p.h.resolveImage(&models.User{LabID: session.LabID})
+		if err != nil {
+			log.Printf("Failed to resolve image of lab %s: %v", session.LabID, err)
+			continue
+		}
+		ref := image.Reference()
+		target, ok := targets[ref]
+		if !ok {
+			target = &poolTarget{image: image}
+			targets[ref] = target
+		}
+		// 同一镜像的多个时段重叠时按人数累加
+		target.size += session.PoolSize
+		target.prestart = target.prestart || session.Prestart
+	}
+	return targets, nil
+}
+
+// refill 补充不足的容器，并删除超出目标的空闲容器
+func (p *ContainerPool) refill(ctx context.Context) {
+	targets, err := p.targets(time.Now())
+	if err != nil {
+		log.Printf("Failed to compute pool targets: %v", err)
+		return
+	}
+
+	for ref, target := range targets {
+		// 逐个创建，避免一次性向 Docker 发起大量请求
+		for p.count(ref) < target.size {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := p.create(ctx, target); err != nil {
+				log.Printf("Failed to create pooled container for %s: %v", ref, err)
+				break
+			}
+		}
+	}
+
+	p.mu.Lock()
+	var excess []*models.User
+	for ref, idle := range p.idle {
+		size := 0
+		if target, ok := targets[ref]; ok {
+			size = target.size
+		}
+		if len(idle) > size {
+			excess = append(excess, idle[size:]...)
+			p.idle[ref] = idle[:size]
+		}
+	}
+	p.mu.Unlock()
+
+	for _, owner := range excess {
+		p.destroy(ctx, owner)
+	}
+}
+
+// create 以占位用户创建一个池容器，按需预先启动。与学生创建容器相同，先在选定的主机上拉取并校验镜像
+func (p *ContainerPool) create(ctx context.Context, target *poolTarget) error {
+	owner := &models.User{ID: poolUserPrefix + randomID()}
+	if err := p.h.scheduleHost(ctx, owner); err != nil {
+		return err
+	}
+	dm, err := p.h.dockerFor(owner)
+	if err != nil {
+		return err
+	}
+	image := target.image
+	err = dm.EnsureImage(ctx, image.Reference(), image.Digest, func(pr docker.PullProgress) {
+		log.Printf("pull %s: %s %s %s", image.Reference(), pr.ID, pr.Status, pr.Progress)
+	})
+	if err != nil {
+		return err
+	}
+
+	containerID, err := p.h.createUserContainer(ctx, owner, image, map[string]string{docker.LabelPool: "true"})
+	if err != nil {
+		p.destroy(ctx, owner)
+		return err
+	}
+	owner.ContainerID = containerID
+
+	if target.prestart {
+		if err := dm.StartContainer(ctx, containerID); err != nil {
+			p.destroy(ctx, owner)
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	ref := target.image.Reference()
+	p.idle[ref] = append(p.idle[ref], owner)
+	p.mu.Unlock()
+	return nil
+}
+
+// destroy 删除池容器及其网络、数据卷并释放端口
+func (p *ContainerPool) destroy(ctx context.Context, owner *models.User) {
+	dm, err := p.h.dockerFor(owner)
+	if err != nil {
+		log.Printf("Failed to destroy pooled container of %s: %v", owner.ID, err)
+		return
+	}
+	if owner.ContainerID != "" {
+		if err := dm.RemoveContainer(ctx, owner.ContainerID); err != nil {
+			log.Printf("Failed to remove pooled container %s: %v", owner.ContainerID, err)
+		}
+	}
+	if owner.Network != "" {
+		if err := p.h.removeUserNetwork(ctx, dm, owner.Network); err != nil {
+			log.Printf("Failed to remove pooled network %s: %v", owner.Network, err)
+		}
+	}
+	if owner.Volume != "" {
+		if err := dm.RemoveVolume(ctx, owner.Volume); err != nil {
+			log.Printf("Failed to remove pooled volume %s: %v", owner.Volume, err)
+		}
+	}
+	p.h.releasePort(owner)
+}
+
+// cleanup 删除所有主机上一次运行遗留且未分配给学生的池容器
+func (p *ContainerPool) cleanup(ctx context.Context) {
+	users, err := p.h.DB.ListUsers()
+	if err != nil {
+		log.Printf("Failed to list users: %v", err)
+		return
+	}
+	assigned := make(map[string]bool)
+	for _, user := range users {
+		assigned[user.ContainerID] = true
+	}
+
+	cfg := p.h.cfg()
+	for _, host := range p.h.Docker.Hosts() {
+		containers, err := host.ListContainers(ctx, docker.LabelPool)
+		if err != nil {
+			log.Printf("Failed to list pooled containers on host %s: %v", host.Name(), err)
+			continue
+		}
+		for _, c := range containers {
+			ownerID := c.Labels[docker.LabelOwner]
+			if assigned[c.ID] || !strings.HasPrefix(ownerID, poolUserPrefix) {
+				continue
+			}
+			// 遗留容器的端口未在启动时恢复，无需释放
+			p.destroy(ctx, &models.User{
+				ID:          ownerID,
+				ContainerID: c.ID,
+				Network:     docker.ResourceName(cfg.NetworkPrefix, ownerID),
+				Volume:      docker.ResourceName(cfg.VolumePrefix, ownerID),
+				DockerHost:  host.Name(),
+			})
+		}
+	}
+}
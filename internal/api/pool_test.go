@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/config"
+	"github.com/cynic-1/blockchain-teaching-system/internal/database"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolTargetsFollowLabSessions(t *testing.T) {
+	handler := setupTestHandler()
+	handler.Config = config.NewConfig()
+	handler.Config.PoolSize = 2
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveImage(&models.Image{ID: "chain-v2", Name: "chain-proxy", Tag: "v2"})
+	mockDB.SaveLab(&models.Lab{ID: "lab1", ImageID: "chain-v2"})
+
+	now := time.Now()
+	// 即将开始（处于提前预热时间内）的时段
+	mockDB.SaveLabSession(&models.LabSession{ID: "s1", LabID: "lab1", StartsAt: now.Add(5 * time.Minute), EndsAt: now.Add(time.Hour), PoolSize: 30, Prestart: true})
+	// 已结束的时段
+	mockDB.SaveLabSession(&models.LabSession{ID: "s2", LabID: "lab1", StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour), PoolSize: 50})
+
+	pool := NewContainerPool(handler)
+	targets, err := pool.targets(now)
+	assert.NoError(t, err)
+	assert.Len(t, targets, 2)
+	assert.Equal(t, 2, targets["chain-proxy:latest"].size)
+	assert.Equal(t, 30, targets["chain-proxy:v2"].size)
+	assert.True(t, targets["chain-proxy:v2"].prestart)
+}
+
+func TestPoolTake(t *testing.T) {
+	pool := NewContainerPool(setupTestHandler())
+	pool.idle["chain-proxy:latest"] = []*models.User{{ID: "pool-1", ContainerID: "c1"}}
+
+	assert.Nil(t, pool.Take("chain-proxy:v2"))
+	owner := pool.Take("chain-proxy:latest")
+	assert.Equal(t, "c1", owner.ContainerID)
+	assert.Nil(t, pool.Take("chain-proxy:latest"))
+}
+
+func TestPoolCreateEnsuresImage(t *testing.T) {
+	// 模拟 Docker 端点：镜像不存在且拉取失败，记录收到的请求
+	var paths []string
+	dockerSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if strings.Contains(r.URL.Path, "/images/create") {
+			http.Error(w, `{"message":"pull access denied"}`, http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	}))
+	defer dockerSrv.Close()
+	dm, err := docker.NewMultiHostManager("1.41", []docker.HostConfig{{Name: "lab-a", Endpoint: "tcp://" + dockerSrv.Listener.Addr().String()}})
+	assert.NoError(t, err)
+
+	handler := setupTestHandler()
+	handler.Config = config.NewConfig()
+	handler.Docker = dm
+	pool := NewContainerPool(handler)
+
+	err = pool.create(context.Background(), &poolTarget{image: &models.Image{Name: "chain-proxy", Tag: "v2"}, size: 1})
+	assert.Error(t, err)
+	assert.NotEmpty(t, paths)
+	// 镜像准备失败时不创建网络、数据卷和容器
+	for _, path := range paths {
+		assert.True(t, strings.Contains(path, "/images/"), path)
+	}
+	assert.Empty(t, pool.idle)
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"github.com/cynic-1/blockchain-teaching-system/internal/config"
+	"github.com/cynic-1/blockchain-teaching-system/internal/database"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 限流的路由组，对应 Config.RateLimits 中的键
+const (
+	RateGroupExec      = "exec"      // exec 和 /api/chain 代理
+	RateGroupSetup     = "setup"     // 集群搭建步骤和流水线
+	RateGroupContainer = "container" // 容器生命周期、数据卷和快照
+)
+
+// 有每日配额的操作，对应 Config.DailyQuotas 中的键
+const (
+	QuotaCreate     = "create"      // 创建容器
+	QuotaBuildChain = "build-chain" // 编译区块链，包括通过 exec、代理和流水线触发的编译
+)
+
+// tokenBucket 一个用户在一个路由组的令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimits 每个用户的令牌桶，保存在内存中，服务重启后重置；当日操作次数保存在数据库中。零值可用
+type rateLimits struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket // 用户ID/路由组 -> 令牌桶
+	// quotaMu 串行化配额的读写，避免同一计数的并发事务冲突
+	quotaMu sync.Mutex
+	now     func() time.Time // 测试中替换时钟
+}
+
+func (l *rateLimits) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// allow 从令牌桶取一个令牌，令牌不足时返回下一个令牌补充所需的时间
+func (l *rateLimits) allow(key string, limit config.RateLimit) (bool, time.Duration) {
+	now := l.clock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+// take 占用 day 当天的一次配额，用完时返回 false
+func (l *rateLimits) take(db database.DatabaseInterface, userID, day, operation string, quota int) (bool, error) {
+	l.quotaMu.Lock()
+	defer l.quotaMu.Unlock()
+	return db.TakeQuota(userID, day, operation, quota)
+}
+
+// refund 归还 day 当天的一次配额
+func (l *rateLimits) refund(db database.DatabaseInterface, userID, day, operation string) error {
+	l.quotaMu.Lock()
+	defer l.quotaMu.Unlock()
+	return db.RefundQuota(userID, day, operation)
+}
+
+// untilTomorrow 返回距离次日零点的时间
+func untilTomorrow(now time.Time) time.Duration {
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	return tomorrow.Sub(now)
+}
+
+// quotaDayKey 请求上下文中保存占用配额日期的键，归还时归还到同一天
+func quotaDayKey(operation string) string {
+	return "quotaDay/" + operation
+}
+
+// isBuildPath 判断 chain-proxy 路径是否为编译区块链的接口
+func isBuildPath(path string) bool {
+	path, _, _ = strings.Cut(path, "?")
+	return path == docker.SetupBuildPath
+}
+
+// userRole 返回用户角色，没有角色的旧用户按学生处理
+func userRole(user *models.User) string {
+	if user.Role == "" {
+		return models.RoleStudent
+	}
+	return user.Role
+}
+
+// respondTooManyRequests 返回 429 和 Retry-After（秒，向上取整）
+func respondTooManyRequests(c *gin.Context, wait time.Duration, code, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	respondError(c, &apiError{Status: http.StatusTooManyRequests, Code: code, Message: message})
+}
+
+// RateLimit 按用户角色对路由组限流的中间件，需放在 JWTMiddleware 之后
+func (h *Handler) RateLimit(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := h.getUserFromContext(c)
+		if err != nil {
+			respondError(c, err)
+			c.Abort()
+			return
+		}
+		limit, ok := h.cfg().RateLimits[userRole(user)][group]
+		if ok && limit.Rate > 0 {
+			if allowed, wait := h.limits.allow(user.ID+"/"+group, limit); !allowed {
+				respondTooManyRequests(c, wait, ErrCodeRateLimited, "Too many requests, retry later")
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// DailyQuota 限制每日操作次数的中间件，需放在 JWTMiddleware 之后。请求失败（状态码不低于 400）时归还配额
+func (h *Handler) DailyQuota(operation string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := h.getUserFromContext(c)
+		if err != nil {
+			respondError(c, err)
+			c.Abort()
+			return
+		}
+		if !h.takeQuota(c, user, operation) {
+			c.Abort()
+			return
+		}
+		defer h.refundOnFailure(c, user, operation)
+		c.Next()
+	}
+}
+
+// takeQuota 占用用户的当日配额，用完时写入 429 响应并返回 false
+func (h *Handler) takeQuota(c *gin.Context, user *models.User, operation string) bool {
+	quota, ok := h.cfg().DailyQuotas[userRole(user)][operation]
+	if !ok {
+		return true
+	}
+	now := h.limits.clock()
+	day := now.Format("2006-01-02")
+	allowed, err := h.limits.take(h.DB, user.ID, day, operation, quota)
+	if err != nil {
+		respondError(c, &apiError{Status: http.StatusInternalServerError, Code: ErrCodeInternal, Message: "Failed to check daily quota"})
+		return false
+	}
+	if !allowed {
+		respondTooManyRequests(c, untilTomorrow(now), ErrCodeQuotaExceeded, "Daily quota for "+operation+" exceeded")
+		return false
+	}
+	c.Set(quotaDayKey(operation), day)
+	return true
+}
+
+// refundQuota 归还未执行的操作占用的配额，归还到占用时的日期，同一请求只归还一次
+func (h *Handler) refundQuota(c *gin.Context, user *models.User, operation string) {
+	if refund := h.deferRefund(c, user, operation); refund != nil {
+		refund()
+	}
+}
+
+// deferRefund 将请求占用的配额交给调用方，返回归还配额的函数，请求结束后不再自动归还。
+// 用于响应返回后才知道结果的后台任务，请求未占用配额时返回 nil
+func (h *Handler) deferRefund(c *gin.Context, user *models.User, operation string) func() {
+	day := c.GetString(quotaDayKey(operation))
+	if day == "" {
+		return nil
+	}
+	c.Set(quotaDayKey(operation), "")
+	userID := user.ID
+	return func() {
+		if err := h.limits.refund(h.DB, userID, day, operation); err != nil {
+			log.Printf("Failed to refund %s quota of user %s: %v", operation, userID, err)
+		}
+	}
+}
+
+// refundOnFailure 请求失败（状态码不低于 400）时归还配额，在处理器写入响应后调用
+func (h *Handler) refundOnFailure(c *gin.Context, user *models.User, operation string) {
+	if c.Writer.Status() >= http.StatusBadRequest {
+		h.refundQuota(c, user, operation)
+	}
+}
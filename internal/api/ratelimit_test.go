@@ -0,0 +1,184 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/config"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupRateLimit 返回限流测试使用的路由和可调整的时钟
+func setupRateLimit(t *testing.T, handler *Handler) (*gin.Engine, *time.Time) {
+	now := time.Date(2024, 5, 1, 23, 59, 0, 0, time.Local)
+	handler.limits.now = func() time.Time { return now }
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", c.GetHeader("X-User"))
+	})
+	router.POST("/exec", handler.RateLimit(RateGroupExec), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/create", handler.DailyQuota(QuotaCreate), func(c *gin.Context) {
+		if c.Query("fail") == "true" {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return router, &now
+}
+
+func doLimited(router *gin.Engine, path, userID string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, nil)
+	req.Header.Set("X-User", userID)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := setupTestHandler()
+	handler.Config = config.NewConfig()
+	handler.Config.RateLimits[models.RoleStudent][RateGroupExec] = config.RateLimit{Rate: 0.5, Burst: 2}
+	handler.DB.SaveUser(&models.User{ID: "student"})
+	handler.DB.SaveUser(&models.User{ID: "teacher", Role: models.RoleAdmin})
+	router, now := setupRateLimit(t, handler)
+
+	assert.Equal(t, http.StatusOK, doLimited(router, "/exec", "student").Code)
+	assert.Equal(t, http.StatusOK, doLimited(router, "/exec", "student").Code)
+	w := doLimited(router, "/exec", "student")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"`+ErrCodeRateLimited+`"`)
+
+	// 令牌按速率补充
+	*now = now.Add(2 * time.Second)
+	assert.Equal(t, http.StatusOK, doLimited(router, "/exec", "student").Code)
+	assert.Equal(t, http.StatusTooManyRequests, doLimited(router, "/exec", "student").Code)
+
+	// 管理员未配置限流
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, doLimited(router, "/exec", "teacher").Code)
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	handler := setupTestHandler()
+	handler.Config = config.NewConfig()
+	handler.Config.DailyQuotas[models.RoleStudent][QuotaCreate] = 2
+	handler.DB.SaveUser(&models.User{ID: "student"})
+	router, now := setupRateLimit(t, handler)
+
+	// 失败的请求不占用配额
+	assert.Equal(t, http.StatusInternalServerError, doLimited(router, "/create?fail=true", "student").Code)
+	assert.Equal(t, http.StatusOK, doLimited(router, "/create", "student").Code)
+	assert.Equal(t, http.StatusOK, doLimited(router, "/create", "student").Code)
+
+	w := doLimited(router, "/create", "student")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"`+ErrCodeQuotaExceeded+`"`)
+
+	// 次数保存在数据库中，服务重启后不重置
+	handler.limits = rateLimits{now: handler.limits.now}
+	assert.Equal(t, http.StatusTooManyRequests, doLimited(router, "/create", "student").Code)
+
+	// 次日零点重置
+	*now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, doLimited(router, "/create", "student").Code)
+}
+
+func TestBuildQuotaThroughExec(t *testing.T) {
+	platform, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("built"))
+	}))
+	handler.Config.DailyQuotas[models.RoleStudent][QuotaBuildChain] = 1
+
+	code, _, _ := doChainRequest(t, platform, "POST", "/api/container/exec", `{"cmd":["mis","/setup/build/chain"]}`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, header, body := doChainRequest(t, platform, "POST", "/api/chain/setup/build/chain", "", nil)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.NotEmpty(t, header.Get("Retry-After"))
+	assert.Contains(t, body, ErrCodeQuotaExceeded)
+}
+
+func TestBuildQuotaOnPipelineResume(t *testing.T) {
+	platform, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "go: build failed", http.StatusInternalServerError)
+	}))
+	handler.Pipelines = NewPipelineRunner(handler)
+	handler.Config.DailyQuotas[models.RoleStudent][QuotaBuildChain] = 1
+	handler.Config.Pipelines = []models.Pipeline{{
+		Name:  "build",
+		Steps: []models.PipelineStep{{Name: "build-chain", Path: "/setup/build/chain"}},
+	}}
+
+	code, _, body := doChainRequest(t, platform, "POST", "/api/pipelines/build/run", "", nil)
+	assert.Equal(t, http.StatusAccepted, code)
+	var accepted struct {
+		Data pipelineAccepted `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(body), &accepted))
+	assert.Equal(t, models.PipelineFailed, waitPipelineRun(t, handler, accepted.Data.RunID).Status)
+
+	// 继续执行会重新编译，同样占用编译配额
+	code, _, body = doChainRequest(t, platform, "POST", "/api/pipeline-runs/"+accepted.Data.RunID+"/resume", "", nil)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Contains(t, body, ErrCodeQuotaExceeded)
+}
+
+// quotaRouter 在 setupChainProxy 的处理器上注册带每日配额的创建和编译接口
+func quotaRouter(handler *Handler) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "student")
+	})
+	router.POST("/create", handler.DailyQuota(QuotaCreate), handler.CreateContainer)
+	router.POST("/build", handler.DailyQuota(QuotaBuildChain), handler.BuildBlockchainBinary)
+	return router
+}
+
+func TestCreateQuotaNotTakenOnReuse(t *testing.T) {
+	_, handler := setupChainProxy(t, http.NotFoundHandler())
+	handler.Config.DailyQuotas[models.RoleStudent][QuotaCreate] = 1
+	router := quotaRouter(handler)
+
+	// 已有容器时复用，不占用创建配额
+	for i := 0; i < 2; i++ {
+		w := doLimited(router, "/create", "student")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"reused":true`)
+	}
+}
+
+func TestAsyncBuildQuotaRefundedOnFailure(t *testing.T) {
+	_, handler := setupChainProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "go: build failed", http.StatusInternalServerError)
+	}))
+	handler.Jobs = NewJobManager(handler)
+	handler.Config.DailyQuotas[models.RoleStudent][QuotaBuildChain] = 1
+	router := quotaRouter(handler)
+
+	// 后台任务失败后归还编译配额，可以再次编译
+	for i := 0; i < 2; i++ {
+		w := doLimited(router, "/build?async=true", "student")
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var accepted struct {
+			Data struct {
+				JobID string `json:"jobID"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+		assert.Eventually(t, func() bool {
+			job, err := handler.Jobs.Get(accepted.Data.JobID)
+			return err == nil && job.Status == models.JobFailed && !handler.busy.isBusy("student")
+		}, time.Second, 10*time.Millisecond)
+	}
+}
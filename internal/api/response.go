@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 容器接口返回的错误码，客户端据此区分错误类型，不依赖错误信息的文本
+const (
+	ErrCodeBadRequest          = "bad_request"
+	ErrCodeNotFound            = "not_found"
+	ErrCodeForbidden           = "forbidden"
+	ErrCodeTooLarge            = "too_large" // 上传、下载或请求体超过大小限制
+	ErrCodeConflict            = "conflict"
+	ErrCodeInternal            = "internal_error"
+	ErrCodeUnavailable         = "unavailable" // 暂时无法处理，如没有可用的 Docker 主机
+	ErrCodeNoContainer         = "no_container"
+	ErrCodeContainerNotRunning = "container_not_running"
+	ErrCodeChainUnreachable    = "chain_unreachable" // 无法连接 chain-proxy
+	ErrCodeChainTimeout        = "chain_timeout"     // chain-proxy 未在截止时间内响应
+	ErrCodeChainRejected       = "chain_rejected"    // chain-proxy 返回 4xx
+	ErrCodeChainFailed         = "chain_failed"      // chain-proxy 返回 5xx 或其他非 2xx
+	ErrCodeChainTooLarge       = "chain_response_too_large"
+	ErrCodeRateLimited         = "rate_limited"   // 请求过于频繁
+	ErrCodeQuotaExceeded       = "quota_exceeded" // 当日操作次数已用完
+)
+
+// apiError 平台返回给客户端的错误
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Step 出错的搭建步骤，仅搭建接口返回
+	Step string `json:"step,omitempty"`
+	// UpstreamStatus 和 Detail 为 chain-proxy 的状态码和解析后的响应体
+	UpstreamStatus int         `json:"upstreamStatus,omitempty"`
+	Detail         interface{} `json:"detail,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// envelope 容器接口统一的响应格式，成功时 Error 为 null，失败时 Data 为 null
+type envelope struct {
+	Data  interface{} `json:"data"`
+	Error *apiError   `json:"error"`
+}
+
+// respondData 返回成功结果
+func respondData(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, envelope{Data: data})
+}
+
+// respondError 将错误转换为平台错误后返回
+func respondError(c *gin.Context, err error) {
+	apiErr := toAPIError(err)
+	c.JSON(apiErr.Status, envelope{Error: apiErr})
+}
+
+// toAPIError 将处理器中出现的错误转换为带错误码的平台错误，未知错误视为内部错误
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		return &apiError{Status: httpErr.StatusCode, Code: statusCode(httpErr.StatusCode), Message: httpErr.Message}
+	}
+	var policyErr *models.ExecPolicyError
+	if errors.As(err, &policyErr) {
+		// 路径和方法不允许为 403，请求体不合法为 400
+		status := http.StatusForbidden
+		if policyErr.Code == models.ExecErrInvalidBody {
+			status = http.StatusBadRequest
+		}
+		return &apiError{Status: status, Code: policyErr.Code, Message: policyErr.Message}
+	}
+	return &apiError{Status: http.StatusInternalServerError, Code: ErrCodeInternal, Message: "Unknown error"}
+}
+
+// statusCode 平台自身错误的状态码对应的错误码
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrCodeBadRequest
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodeTooLarge
+	default:
+		return ErrCodeInternal
+	}
+}
+
+// newAPIError 返回按状态码确定错误码的平台错误
+func newAPIError(status int, message string) *apiError {
+	return &apiError{Status: status, Code: statusCode(status), Message: message}
+}
+
+// chainError 将请求 chain-proxy 的错误转换为平台错误：超时为 504，容器未运行为 409，
+// 上游 4xx 保留原状态码，上游 5xx 和连接失败为 502
+func chainError(err error) *apiError {
+	var statusErr *docker.ChainStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		apiErr := &apiError{
+			Status:         http.StatusBadGateway,
+			Code:           ErrCodeChainFailed,
+			Message:        fmt.Sprintf("chain-proxy returned %d", statusErr.StatusCode),
+			UpstreamStatus: statusErr.StatusCode,
+			Detail:         docker.ChainValue([]byte(statusErr.Body)),
+		}
+		if statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 {
+			apiErr.Status = statusErr.StatusCode
+			apiErr.Code = ErrCodeChainRejected
+		}
+		return apiErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return &apiError{Status: http.StatusGatewayTimeout, Code: ErrCodeChainTimeout, Message: err.Error()}
+	case errors.Is(err, docker.ErrContainerNotRunning):
+		return &apiError{Status: http.StatusConflict, Code: ErrCodeContainerNotRunning, Message: err.Error()}
+	case errors.Is(err, docker.ErrChainResponseTooLarge):
+		return &apiError{Status: http.StatusBadGateway, Code: ErrCodeChainTooLarge, Message: err.Error()}
+	default:
+		return &apiError{Status: http.StatusBadGateway, Code: ErrCodeChainUnreachable, Message: err.Error()}
+	}
+}
+
+// noContainerError 用户尚未创建容器
+func noContainerError() *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: ErrCodeNoContainer, Message: "No container, create one first"}
+}
+
+// chainResult 调用 chain-proxy 成功时返回的数据
+type chainResult struct {
+	Step       string      `json:"step,omitempty"`
+	StatusCode int         `json:"statusCode"`
+	Result     interface{} `json:"result"` // JSON 响应解码后的值，其他响应为文本
+}
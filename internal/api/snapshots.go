@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// 快照名只允许字母、数字和 _ . -，不能以 . 或 - 开头，最长 64 个字符，符合镜像 tag 的语法，同时用作归档文件名
+var snapshotNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+// snapshotArchivePath 返回快照数据卷归档在宿主机上的路径
+func (h *Handler) snapshotArchivePath(userID, name string) string {
+	return filepath.Join(h.cfg().SnapshotDir, docker.ResourceName("", userID), name+".tar")
+}
+
+func (h *Handler) ListSnapshots(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	snapshots, err := h.DB.ListSnapshots(userID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to list snapshots"))
+		return
+	}
+	respondData(c, http.StatusOK, gin.H{"snapshots": snapshots, "quota": h.cfg().MaxSnapshotsPerUser})
+}
+
+// CreateSnapshot 提交当前容器为镜像并导出工作目录，保存为命名快照
+func (h *Handler) CreateSnapshot(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	if !snapshotNamePattern.MatchString(req.Name) {
+		respondError(c, newAPIError(http.StatusBadRequest, "Invalid snapshot name"))
+		return
+	}
+
+	user, unlock, err := h.lockUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	defer unlock()
+
+	if _, err := h.DB.GetSnapshot(user.ID, req.Name); err == nil {
+		respondError(c, newAPIError(http.StatusConflict, "Snapshot already exists"))
+		return
+	}
+	snapshots, err := h.DB.ListSnapshots(user.ID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to list snapshots"))
+		return
+	}
+	if len(snapshots) >= h.cfg().MaxSnapshotsPerUser {
+		respondError(c, newAPIError(http.StatusForbidden, "Snapshot quota exceeded"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	exists, err := dm.ContainerExists(ctx, user.ContainerID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to inspect existing container"))
+		return
+	}
+	if !exists {
+		respondError(c, newAPIError(http.StatusBadRequest, "No container to snapshot"))
+		return
+	}
+
+	// tag 随机生成并保存在快照记录中，不同用户、删除后重建的同名快照的镜像互不覆盖
+	snapshot := &models.Snapshot{
+		UserID:          user.ID,
+		Name:            req.Name,
+		ImageRepository: h.cfg().SnapshotRepository,
+		ImageTag:        "snap-" + randomID(),
+		DockerHost:      user.DockerHost,
+		CreatedAt:       time.Now(),
+	}
+	imageRef := snapshot.ImageRepository + ":" + snapshot.ImageTag
+	if _, err := dm.CommitContainer(ctx, user.ContainerID, imageRef); err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to commit container: "+err.Error()))
+		return
+	}
+
+	size, err := h.exportWorkDir(ctx, dm, user.ContainerID, h.snapshotArchivePath(user.ID, req.Name))
+	if err == nil {
+		snapshot.ArchiveSize = size
+		err = h.DB.SaveSnapshot(snapshot)
+	}
+	if err != nil {
+		_ = h.removeSnapshotData(ctx, snapshot)
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to save snapshot: "+err.Error()))
+		return
+	}
+	respondData(c, http.StatusOK, snapshot)
+}
+
+// exportWorkDir 将容器工作目录（数据卷）导出为宿主机上的 tar 文件，返回文件大小
+func (h *Handler) exportWorkDir(ctx context.Context, dm *docker.DockerManager, containerID, archivePath string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0o750); err != nil {
+		return 0, err
+	}
+	reader, err := dm.CopyFromContainer(ctx, containerID, h.cfg().ClusterWorkDir)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return size, err
+}
+
+// RestoreSnapshot 用快照镜像在新的数据卷上重建容器并还原归档，成功后才替换原有容器和数据卷
+func (h *Handler) RestoreSnapshot(c *gin.Context) {
+	user, unlock, err := h.lockUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	defer unlock()
+	// 还原会删除容器，搭建操作执行中时不还原
+	release, err := h.busy.acquire(user.ID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusConflict, err.Error()))
+		return
+	}
+	defer release()
+
+	snapshot, err := h.DB.GetSnapshot(user.ID, c.Param("name"))
+	if err != nil {
+		respondError(c, newAPIError(http.StatusNotFound, "Snapshot not found"))
+		return
+	}
+	archive, err := os.Open(h.snapshotArchivePath(user.ID, snapshot.Name))
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Snapshot archive is missing"))
+		return
+	}
+	defer archive.Close()
+
+	// 快照镜像沿用用户当前实验镜像的资源配置
+	image, err := h.resolveImage(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	snapshotImage := &models.Image{
+		ID:        image.ID,
+		Name:      snapshot.ImageRepository,
+		Tag:       snapshot.ImageTag,
+		Resources: image.Resources,
+	}
+
+	ctx := c.Request.Context()
+	// 快照镜像只存在于创建它的主机上，用户的数据卷也在该主机
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	// 先在新数据卷上创建容器并解压归档，全部成功后才替换原有容器和数据卷，失败时用户的环境保持不变。
+	// 新容器只创建不启动，不会与原容器争用端口
+	restored := *user
+	restored.Volume = docker.ResourceName(h.cfg().VolumePrefix, user.ID) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	containerID, err := h.createUserContainer(ctx, &restored, snapshotImage, nil)
+	if err != nil {
+		_ = dm.RemoveVolume(ctx, restored.Volume)
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to create container: "+err.Error()))
+		return
+	}
+	discard := func() {
+		_ = dm.RemoveContainer(ctx, containerID)
+		_ = dm.RemoveVolume(ctx, restored.Volume)
+		if user.Port == "" {
+			h.releasePort(&restored)
+		}
+	}
+	// 归档的顶层目录为工作目录本身，因此解压到其父目录
+	if err := dm.CopyToContainer(ctx, containerID, path.Dir(h.cfg().ClusterWorkDir), archive); err != nil {
+		discard()
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to restore volume: "+err.Error()))
+		return
+	}
+
+	exists, err := dm.ContainerExists(ctx, user.ContainerID)
+	if err != nil {
+		discard()
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to inspect existing container"))
+		return
+	}
+	if exists {
+		if err := dm.RemoveContainer(ctx, user.ContainerID); err != nil {
+			discard()
+			respondError(c, newAPIError(http.StatusInternalServerError, "Failed to remove existing container"))
+			return
+		}
+	}
+	restored.ContainerID = containerID
+	if err := h.DB.SaveUser(&restored); err != nil {
+		// 原容器已删除，记录不再指向它，原数据卷保留以便恢复
+		discard()
+		user.ContainerID = ""
+		_ = h.DB.SaveUser(user)
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to update user"))
+		return
+	}
+	if user.Volume != "" {
+		if err := dm.RemoveVolume(ctx, user.Volume); err != nil {
+			log.Printf("Failed to remove volume %s replaced by snapshot %s: %v", user.Volume, snapshot.Name, err)
+		}
+	}
+	respondData(c, http.StatusOK, gin.H{"containerID": containerID, "snapshot": snapshot.Name})
+}
+
+func (h *Handler) DeleteSnapshot(c *gin.Context) {
+	user, unlock, err := h.lockUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	defer unlock()
+
+	snapshot, err := h.DB.GetSnapshot(user.ID, c.Param("name"))
+	if err != nil {
+		respondError(c, newAPIError(http.StatusNotFound, "Snapshot not found"))
+		return
+	}
+	if err := h.deleteSnapshot(c.Request.Context(), snapshot); err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	respondData(c, http.StatusOK, gin.H{"snapshot": snapshot.Name})
+}
+
+// deleteSnapshot 删除快照镜像、归档文件和快照记录
+func (h *Handler) deleteSnapshot(ctx context.Context, snapshot *models.Snapshot) error {
+	if err := h.removeSnapshotData(ctx, snapshot); err != nil {
+		return err
+	}
+	return h.DB.DeleteSnapshot(snapshot.UserID, snapshot.Name)
+}
+
+// removeSnapshotData 删除快照镜像和归档文件
+func (h *Handler) removeSnapshotData(ctx context.Context, snapshot *models.Snapshot) error {
+	imageRef := snapshot.ImageRepository + ":" + snapshot.ImageTag
+	dm, err := h.Docker.Host(snapshot.DockerHost)
+	if err != nil {
+		return fmt.Errorf("failed to remove snapshot image: %v", err)
+	}
+	if err := dm.RemoveImage(ctx, imageRef); err != nil {
+		return fmt.Errorf("failed to remove snapshot image: %v", err)
+	}
+	err = os.Remove(h.snapshotArchivePath(snapshot.UserID, snapshot.Name))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove snapshot archive: %v", err)
+	}
+	return nil
+}
+
+// deleteUserSnapshots 删除用户的全部快照，用于注销账号
+func (h *Handler) deleteUserSnapshots(ctx context.Context, userID string) error {
+	snapshots, err := h.DB.ListSnapshots(userID)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if err := h.deleteSnapshot(ctx, snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
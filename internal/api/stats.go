@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 同时读取容器资源使用情况的数量上限
+const statsConcurrency = 8
+
+// UserUsage 用户容器当前的资源使用情况
+type UserUsage struct {
+	UserID      string                `json:"userID"`
+	LabID       string                `json:"labID"`
+	ContainerID string                `json:"containerID"`
+	Usage       *docker.ResourceUsage `json:"usage"`
+}
+
+// StatsCollector 定期采集所有学生容器的资源使用情况，为每个用户保留最近的历史记录
+type StatsCollector struct {
+	h *Handler
+
+	mu      sync.Mutex
+	history map[string][]docker.ResourceUsage // 用户ID -> 按时间排列的采样
+	owners  map[string]*models.User           // 用户ID -> 最近一次采样时的用户记录
+}
+
+func NewStatsCollector(h *Handler) *StatsCollector {
+	return &StatsCollector{
+		h:       h,
+		history: make(map[string][]docker.ResourceUsage),
+		owners:  make(map[string]*models.User),
+	}
+}
+
+// Run 定期采集，直到 ctx 结束
+func (s *StatsCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.h.cfg().StatsInterval)
+	defer ticker.Stop()
+	for {
+		s.collect(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect 采集所有有容器的用户，并丢弃已没有容器的用户的历史
+func (s *StatsCollector) collect(ctx context.Context) {
+	users, err := s.h.DB.ListUsers()
+	if err != nil {
+		log.Printf("Failed to list users: %v", err)
+		return
+	}
+
+	active := make(map[string]bool)
+	sem := make(chan struct{}, statsConcurrency)
+	var wg sync.WaitGroup
+	for _, user := range users {
+		if user.ContainerID == "" {
+			continue
+		}
+		active[user.ID] = true
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(user *models.User) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			dm, err := s.h.dockerFor(user)
+			if err != nil {
+				return
+			}
+			usage, err := dm.ContainerUsage(ctx, user.ContainerID)
+			if err != nil {
+				return
+			}
+			s.record(user, *usage)
+		}(user)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID := range s.history {
+		if !active[userID] {
+			delete(s.history, userID)
+			delete(s.owners, userID)
+		}
+	}
+}
+
+// record 保存一次采样，超出历史长度时丢弃最早的采样
+func (s *StatsCollector) record(user *models.User, usage docker.ResourceUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := append(s.history[user.ID], usage)
+	if max := s.h.cfg().StatsHistorySize; len(history) > max {
+		history = history[len(history)-max:]
+	}
+	s.history[user.ID] = history
+	s.owners[user.ID] = user
+}
+
+// History 返回用户的历史采样
+func (s *StatsCollector) History(userID string) []docker.ResourceUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]docker.ResourceUsage(nil), s.history[userID]...)
+}
+
+// Current 返回所有用户最近一次的采样，按 CPU 使用率从高到低排列
+func (s *StatsCollector) Current() []UserUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := make([]UserUsage, 0, len(s.history))
+	for userID, history := range s.history {
+		owner := s.owners[userID]
+		usage := history[len(history)-1]
+		current = append(current, UserUsage{
+			UserID:      userID,
+			LabID:       owner.LabID,
+			ContainerID: owner.ContainerID,
+			Usage:       &usage,
+		})
+	}
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].Usage.CPUPercent > current[j].Usage.CPUPercent
+	})
+	return current
+}
+
+// ContainerStats 返回当前用户容器最近一次和历史的资源使用情况
+func (h *Handler) ContainerStats(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if h.Stats == nil {
+		respondError(c, newAPIError(http.StatusServiceUnavailable, "Stats collector is not running"))
+		return
+	}
+
+	history := h.Stats.History(userID)
+	var current *docker.ResourceUsage
+	if len(history) > 0 {
+		current = &history[len(history)-1]
+	}
+	respondData(c, http.StatusOK, gin.H{"current": current, "history": history})
+}
+
+// AdminStats 返回所有学生容器当前的资源使用情况及总量，可按 labID 过滤
+func (h *Handler) AdminStats(c *gin.Context) {
+	if h.Stats == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Stats collector is not running"})
+		return
+	}
+
+	labID := c.Query("labID")
+	users := make([]UserUsage, 0)
+	var totalCPU float64
+	var totalMemory uint64
+	for _, u := range h.Stats.Current() {
+		if labID != "" && u.LabID != labID {
+			continue
+		}
+		users = append(users, u)
+		totalCPU += u.Usage.CPUPercent
+		totalMemory += u.Usage.MemoryBytes
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"total": gin.H{"containers": len(users), "cpuPercent": totalCPU, "memoryBytes": totalMemory},
+	})
+}
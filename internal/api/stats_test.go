@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/config"
+	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsCollectorHistoryAndAdminView(t *testing.T) {
+	handler := setupTestHandler()
+	handler.Config = config.NewConfig()
+	handler.Config.StatsHistorySize = 2
+	handler.Stats = NewStatsCollector(handler)
+
+	alice := &models.User{ID: "alice", LabID: "lab1", ContainerID: "c1"}
+	bob := &models.User{ID: "bob", LabID: "lab2", ContainerID: "c2"}
+	handler.Stats.record(alice, docker.ResourceUsage{CPUPercent: 10, MemoryBytes: 100})
+	handler.Stats.record(alice, docker.ResourceUsage{CPUPercent: 20, MemoryBytes: 200})
+	handler.Stats.record(alice, docker.ResourceUsage{CPUPercent: 30, MemoryBytes: 300})
+	handler.Stats.record(bob, docker.ResourceUsage{CPUPercent: 150, MemoryBytes: 1000})
+
+	history := handler.Stats.History("alice")
+	assert.Len(t, history, 2)
+	assert.Equal(t, 20.0, history[0].CPUPercent)
+
+	router := gin.Default()
+	router.GET("/admin/stats", handler.AdminStats)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/stats", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Users []UserUsage `json:"users"`
+		Total struct {
+			CPUPercent  float64 `json:"cpuPercent"`
+			MemoryBytes uint64  `json:"memoryBytes"`
+		} `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Users, 2)
+	assert.Equal(t, "bob", resp.Users[0].UserID)
+	assert.Equal(t, 180.0, resp.Total.CPUPercent)
+	assert.Equal(t, uint64(1300), resp.Total.MemoryBytes)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/stats?labID=lab1", nil)
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Users, 1)
+	assert.Equal(t, "alice", resp.Users[0].UserID)
+}
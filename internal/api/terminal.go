@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cynic-1/blockchain-teaching-system/internal/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"time"
+)
+
+var terminalUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	// 客户端通过子协议传递 token，握手时必须选中该子协议，否则浏览器会关闭连接
+	Subprotocols: []string{auth.WebSocketProtocol},
+}
+
+// terminalMessage 客户端发送的终端消息，type 为 input 或 resize
+type terminalMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Rows uint   `json:"rows,omitempty"`
+	Cols uint   `json:"cols,omitempty"`
+}
+
+// terminalEnabled 判断用户所在实验是否开启了网页终端
+func (h *Handler) terminalEnabled(labID string) (bool, error) {
+	if labID == "" {
+		return h.cfg().TerminalEnabled, nil
+	}
+	lab, err := h.DB.GetLab(labID)
+	if err != nil {
+		return false, &httpError{http.StatusInternalServerError, "Failed to get lab"}
+	}
+	return lab.TerminalEnabled, nil
+}
+
+// Terminal 通过 WebSocket 提供容器内的交互式终端。客户端以文本消息发送 JSON 格式的
+// 输入和窗口大小调整，服务端以二进制消息返回终端输出，超过空闲时长无输入时断开
+func (h *Handler) Terminal(c *gin.Context) {
+	user, err := h.getUserFromContext(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	enabled, err := h.terminalEnabled(user.LabID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if !enabled {
+		respondError(c, newAPIError(http.StatusForbidden, "Terminal is not enabled for this lab"))
+		return
+	}
+
+	dm, err := h.dockerFor(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	exists, err := dm.ContainerExists(c.Request.Context(), user.ContainerID)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusInternalServerError, "Failed to inspect existing container"))
+		return
+	}
+	if !exists {
+		respondError(c, newAPIError(http.StatusNotFound, "Container not found"))
+		return
+	}
+
+	// Upgrade 失败时已向客户端写入错误响应
+	conn, err := terminalUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// 会话生命周期由 WebSocket 连接决定，不使用已被劫持的请求 ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	terminal, err := dm.OpenTerminal(ctx, user.ContainerID, h.cfg().TerminalShell)
+	if err != nil {
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()))
+		return
+	}
+	defer terminal.Close()
+
+	// 终端输出转发到 WebSocket，shell 退出时关闭连接使读循环结束
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		for {
+			n, err := terminal.Read(buf)
+			if n > 0 {
+				if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "terminal exited"))
+				return
+			}
+		}
+	}()
+
+	idleTimeout := h.cfg().TerminalIdleTimeout
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg terminalMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case "input":
+			if _, err := terminal.Write([]byte(msg.Data)); err != nil {
+				return
+			}
+		case "resize":
+			if msg.Rows > 0 && msg.Cols > 0 {
+				if err := terminal.Resize(ctx, msg.Rows, msg.Cols); err != nil {
+					log.Printf("Failed to resize terminal of user %s: %v", user.ID, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cynic-1/blockchain-teaching-system/internal/database"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerminalDisabledForLab(t *testing.T) {
+	handler := setupTestHandler()
+	mockDB := handler.DB.(*database.MockDatabase)
+	mockDB.SaveLab(&models.Lab{ID: "lab1"})
+	mockDB.SaveLab(&models.Lab{ID: "lab2", TerminalEnabled: true})
+	mockDB.SaveUser(&models.User{ID: "student", LabID: "lab1", ContainerID: "abc"})
+
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "student")
+	})
+	router.GET("/container/terminal", handler.Terminal)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/container/terminal", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"`+ErrCodeForbidden+`"`)
+
+	enabled, err := handler.terminalEnabled("lab2")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	// 未加入实验时使用全局配置，默认关闭
+	enabled, err = handler.terminalEnabled("")
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+}
@@ -190,9 +190,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -206,9 +206,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -222,9 +222,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -238,9 +238,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -254,9 +254,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -270,9 +270,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -286,9 +286,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -302,9 +302,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -318,9 +318,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	t.Run("Exec", func(t *testing.T) {
@@ -334,9 +334,9 @@ func TestBlockchainOperations(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]string
+		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
-		t.Log(response["output"])
+		t.Log(response["data"])
 	})
 
 	// 停止容器
@@ -56,9 +56,35 @@ func CreateToken(userID string) (string, error) {
 	return token, nil
 }
 
+// WebSocketProtocol 浏览器发起 WebSocket 连接时无法设置 Authorization 请求头，token 通过子协议传递：
+// new WebSocket(url, [WebSocketProtocol, token])。不使用查询参数，避免 token 被写入访问日志
+const WebSocketProtocol = "bearer"
+
+// webSocketToken 返回 Sec-WebSocket-Protocol 中紧跟在 WebSocketProtocol 之后的 token
+func webSocketToken(c *gin.Context) string {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		return ""
+	}
+	var protocols []string
+	for _, header := range c.Request.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == WebSocketProtocol {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
 func JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		if token := webSocketToken(c); authHeader == "" && token != "" {
+			authHeader = "Bearer " + token
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			c.Abort()
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTMiddlewareWebSocketProtocol(t *testing.T) {
+	assert.NoError(t, InitSecretKey())
+	token, err := CreateToken("student")
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/ws", JWTMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("userID"))
+	})
+
+	// token 通过子协议传递
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Protocol", WebSocketProtocol+", "+token)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "student", w.Body.String())
+
+	// 不再接受查询参数中的 token，避免写入访问日志
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/ws?token="+token, nil)
+	req.Header.Set("Upgrade", "websocket")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -1,9 +1,142 @@
 package config
 
+import (
+	"fmt"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"time"
+)
+
+// DockerHost 一台 Docker 主机
+type DockerHost struct {
+	Name     string
+	Endpoint string // 如 tcp://10.0.0.2:2375
+	Weight   int    // 容量权重，新容器调度到 容器数/权重 最小的主机
+	// ProxyAddress 访问该主机上容器发布端口的地址，为空时直接访问容器 IP
+	ProxyAddress string
+}
+
+// RateLimit 令牌桶限流：每秒补充 Rate 个令牌，桶容量为 Burst
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
 type Config struct {
 	ServerPort       string
 	DockerAPIVersion string
 	BadgerDBPath     string
+
+	// DockerHosts 实验机上的 Docker 主机，为空时使用环境变量中的单个 Docker 主机
+	DockerHosts []DockerHost
+
+	// DefaultImage 用户未加入实验或实验未指定镜像时使用的镜像
+	DefaultImage string
+	// AdminUsers 服务启动时被授予管理员角色的已注册用户ID，默认为空，需由部署者显式配置
+	AdminUsers []string
+
+	// PortRangeStart、PortRangeEnd 为学生容器分配宿主机端口的范围
+	PortRangeStart int
+	PortRangeEnd   int
+	// PublishedPorts 需要发布到宿主机的容器端口，第一个为 chain-proxy 服务端口，
+	// 其余（如节点 RPC 端口）依次映射到用户端口之后的连续端口
+	PublishedPorts []string
+
+	// NetworkPrefix 学生专属网络的名称前缀，网络名为前缀加用户ID
+	NetworkPrefix string
+	// InstructorContainers 教师工具容器的名称，每个学生网络创建后连接这些容器，
+	// 教师工具可以访问所有学生容器，学生之间仍然隔离。多主机时每台主机上需有同名容器
+	InstructorContainers []string
+
+	// VolumePrefix 学生数据卷的名称前缀，卷名为前缀加用户ID
+	VolumePrefix string
+	// ClusterWorkDir chain-proxy 在容器内的工作目录，数据卷挂载于此，需与镜像保持一致
+	ClusterWorkDir string
+
+	// SnapshotDir 保存快照数据卷归档的宿主机目录
+	SnapshotDir string
+	// SnapshotRepository 快照镜像的仓库名
+	SnapshotRepository string
+	// MaxSnapshotsPerUser 每个用户可保存的快照数量上限
+	MaxSnapshotsPerUser int
+
+	// PoolSize 非实验课时段为默认镜像预创建的容器数量，0 表示不预热
+	PoolSize int
+	// PoolPrestart 是否预先启动默认池中的容器
+	PoolPrestart bool
+	// PoolRefillInterval 后台补充容器池的间隔
+	PoolRefillInterval time.Duration
+	// PoolLeadTime 实验课开始前提前预热的时间
+	PoolLeadTime time.Duration
+
+	// QueueWorkers 同时执行的 Docker 操作数量上限
+	QueueWorkers int
+	// OperationRetention 已完成的操作保留供查询的时长
+	OperationRetention time.Duration
+
+	// HealthCheckCmd 学生容器内检查 chain-proxy 服务的命令，为空时不配置 Docker 健康检查，
+	// 只由后台从宿主机探测。镜像需要自带命令中用到的工具
+	HealthCheckCmd string
+	// HealthCheckInterval、HealthCheckRetries 健康检查间隔和判定为 unhealthy 的连续失败次数，
+	// 宿主机探测同样在连续失败 HealthCheckRetries 次后重启容器
+	HealthCheckInterval time.Duration
+	HealthCheckRetries  int
+	// RestartPolicy 学生容器的 Docker 重启策略，为空时不自动重启
+	RestartPolicy string
+	// AutoRestartUnhealthy 是否由后台定期重启 unhealthy 的容器
+	AutoRestartUnhealthy bool
+	// HealthMonitorInterval 后台检查 unhealthy 容器的间隔
+	HealthMonitorInterval time.Duration
+
+	// TerminalEnabled 未加入实验的学生是否可以使用网页终端，实验内由实验配置决定
+	TerminalEnabled bool
+	// TerminalShell 网页终端在容器内启动的命令
+	TerminalShell []string
+	// TerminalIdleTimeout 网页终端无输入时自动断开的时长
+	TerminalIdleTimeout time.Duration
+
+	// FileAllowedPaths 允许上传和下载的容器内目录
+	FileAllowedPaths []string
+	// MaxUploadSize、MaxDownloadSize 单次上传和下载的大小上限（字节）
+	MaxUploadSize   int64
+	MaxDownloadSize int64
+
+	// EventHistorySize 每个用户保留的最近容器事件数量
+	EventHistorySize int
+
+	// StatsInterval 采集容器资源使用情况的间隔
+	StatsInterval time.Duration
+	// StatsHistorySize 每个用户保留的资源使用采样数量
+	StatsHistorySize int
+
+	// MaxClusterNodes 学生集群的节点数上限
+	MaxClusterNodes int
+
+	// ExecPolicy 学生可通过 exec 和 /api/chain 代理调用的 chain-proxy 接口
+	ExecPolicy models.ExecPolicy
+	// ExecHeaders exec 请求可以携带并转发给 chain-proxy 的请求头
+	ExecHeaders []string
+	// ChainMaxRequestSize、ChainMaxResponseSize /api/chain 代理的请求体和响应体大小上限（字节）
+	ChainMaxRequestSize  int64
+	ChainMaxResponseSize int64
+	// ChainTimeout chain-proxy 请求的默认超时，超时返回 504
+	ChainTimeout time.Duration
+	// ChainTimeouts 按 chain-proxy 路径单独配置的超时，如编译区块链需要更长时间
+	ChainTimeouts map[string]time.Duration
+
+	// JobRetention 已结束的搭建任务的保留时长
+	JobRetention time.Duration
+	// JobMaxOutput 每个搭建任务保存的输出上限（字节）
+	JobMaxOutput int
+
+	// Pipelines 可供学生运行的一键搭建流水线，PipelineDir 中的 YAML/JSON 定义在启动时追加，同名时覆盖
+	Pipelines   []models.Pipeline
+	PipelineDir string
+
+	// RateLimits 按角色和路由组（exec、setup、container）的每用户限流，未配置的角色或路由组不限流，
+	// 没有角色的旧用户按学生处理
+	RateLimits map[string]map[string]RateLimit
+	// DailyQuotas 按角色的每日操作次数上限（create、build-chain），未配置的操作不限，按服务器本地时间零点重置
+	DailyQuotas map[string]map[string]int
 }
 
 func NewConfig() *Config {
@@ -11,5 +144,140 @@ func NewConfig() *Config {
 		ServerPort:       ":8080",
 		DockerAPIVersion: "1.41",
 		BadgerDBPath:     "./badger",
+		DefaultImage:     "chain-proxy",
+		PortRangeStart:   20000,
+		PortRangeEnd:     29999,
+		PublishedPorts:   []string{"8080"},
+		NetworkPrefix:    "bts-net-",
+		VolumePrefix:     "bts-data-",
+		ClusterWorkDir:   "/app/workdir",
+
+		SnapshotDir:         "./snapshots",
+		SnapshotRepository:  "bts-snapshot",
+		MaxSnapshotsPerUser: 5,
+
+		PoolRefillInterval: 30 * time.Second,
+		PoolLeadTime:       15 * time.Minute,
+
+		QueueWorkers:       4,
+		OperationRetention: time.Hour,
+
+		// 学生镜像不一定带有 curl，默认只从宿主机探测 chain-proxy
+		HealthCheckCmd:        "",
+		HealthCheckInterval:   30 * time.Second,
+		HealthCheckRetries:    3,
+		RestartPolicy:         "unless-stopped",
+		AutoRestartUnhealthy:  true,
+		HealthMonitorInterval: time.Minute,
+
+		TerminalShell:       []string{"/bin/sh"},
+		TerminalIdleTimeout: 10 * time.Minute,
+
+		FileAllowedPaths: []string{"/app/workdir"},
+		MaxUploadSize:    10 << 20,
+		MaxDownloadSize:  50 << 20,
+
+		EventHistorySize: 50,
+
+		StatsInterval:    15 * time.Second,
+		StatsHistorySize: 240,
+
+		MaxClusterNodes: 16,
+
+		ExecPolicy:           defaultExecPolicy(),
+		ExecHeaders:          []string{"Accept", "Content-Type"},
+		ChainMaxRequestSize:  1 << 20,
+		ChainMaxResponseSize: 32 << 20,
+		ChainTimeout:         30 * time.Second,
+		ChainTimeouts: map[string]time.Duration{
+			"/setup/build/chain":   10 * time.Minute,
+			"/setup/cluster/start": 2 * time.Minute,
+		},
+
+		JobRetention: 24 * time.Hour,
+		JobMaxOutput: 1 << 20,
+
+		Pipelines:   []models.Pipeline{defaultPipeline()},
+		PipelineDir: "./pipelines",
+
+		// 管理员不限流
+		RateLimits: map[string]map[string]RateLimit{
+			models.RoleStudent: {
+				"exec":      {Rate: 5, Burst: 20},
+				"setup":     {Rate: 1, Burst: 10},
+				"container": {Rate: 0.2, Burst: 5},
+			},
+		},
+		DailyQuotas: map[string]map[string]int{
+			models.RoleStudent: {
+				"create":      20,
+				"build-chain": 30,
+			},
+		},
+	}
+}
+
+// Validate 检查配置项的取值，后台任务的间隔为 0 或负数时 time.NewTicker 会 panic
+func (c *Config) Validate() error {
+	intervals := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"HealthMonitorInterval", c.HealthMonitorInterval},
+		{"StatsInterval", c.StatsInterval},
+		{"PoolRefillInterval", c.PoolRefillInterval},
+		{"TerminalIdleTimeout", c.TerminalIdleTimeout},
+	}
+	for _, interval := range intervals {
+		if interval.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", interval.name, interval.value)
+		}
+	}
+	return nil
+}
+
+// defaultExecPolicy 集群搭建流程和链状态查询所需的接口
+func defaultExecPolicy() models.ExecPolicy {
+	post := []string{"POST"}
+	query := []string{"GET"}
+	return models.ExecPolicy{
+		{
+			Path:     "/setup/new/factory",
+			Methods:  post,
+			Body:     map[string]string{"nodeCount": "number", "stakeQuota": "number", "windowSize": "number"},
+			Required: []string{"nodeCount", "stakeQuota", "windowSize"},
+		},
+		{Path: "/setup/reset/workdir", Methods: post},
+		{Path: "/setup/genesis/addrs", Methods: post},
+		{Path: "/setup/genesis/random", Methods: post},
+		{Path: "/setup/genesis/template", Methods: post},
+		{Path: "/setup/new/cluster", Methods: post},
+		{Path: "/setup/build/chain", Methods: post},
+		{Path: "/setup/cluster/start", Methods: post},
+		{Path: "/setup/cluster/stop", Methods: post},
+		{Path: "/proxy/*/consensus", Methods: query},
+		{Path: "/proxy/*/txpool", Methods: query},
+		{Path: "/proxy/*/blocks/height/*", Methods: query},
+	}
+}
+
+// defaultPipeline 集成测试中的完整搭建流程：创建集群、编译、启动、检查存活后停止
+func defaultPipeline() models.Pipeline {
+	return models.Pipeline{
+		Name:        "lab-cluster",
+		Description: "Create, build, start and verify a 4-node local cluster",
+		Steps: []models.PipelineStep{
+			{Name: "factory", Path: "/setup/new/factory", Body: `{"nodeCount":4,"stakeQuota":9999,"windowSize":4}`},
+			{Name: "reset-workdir", Path: "/setup/reset/workdir"},
+			{Name: "genesis-addrs", Path: "/setup/genesis/addrs"},
+			{Name: "genesis-random", Path: "/setup/genesis/random"},
+			{Name: "genesis-template", Path: "/setup/genesis/template"},
+			{Name: "new-cluster", Path: "/setup/new/cluster"},
+			{Name: "build-chain", Path: "/setup/build/chain", Retries: 1, RetryDelay: 5 * time.Second},
+			{Name: "cluster-start", Path: "/setup/cluster/start"},
+			// 节点启动后需要一段时间才能响应
+			{Name: "liveness", Method: "GET", Path: "/proxy/-1/consensus", Retries: 5, RetryDelay: 3 * time.Second},
+			{Name: "cluster-stop", Path: "/setup/cluster/stop"},
+		},
 	}
 }
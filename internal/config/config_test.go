@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateIntervals(t *testing.T) {
+	assert.NoError(t, NewConfig().Validate())
+
+	for _, set := range []func(c *Config){
+		func(c *Config) { c.HealthMonitorInterval = 0 },
+		func(c *Config) { c.StatsInterval = -time.Second },
+		func(c *Config) { c.PoolRefillInterval = 0 },
+		func(c *Config) { c.TerminalIdleTimeout = 0 },
+	} {
+		c := NewConfig()
+		set(c)
+		assert.Error(t, c.Validate())
+	}
+}
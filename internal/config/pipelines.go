@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadPipelines 读取目录中的 .yaml、.yml 和 .json 流水线定义，目录不存在时返回空
+func LoadPipelines(dir string) ([]models.Pipeline, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pipelines []models.Pipeline
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// JSON 是 YAML 的子集，两种格式使用同一解析器
+		var pipeline models.Pipeline
+		if err := yaml.Unmarshal(data, &pipeline); err != nil {
+			return nil, fmt.Errorf("%s: %v", entry.Name(), err)
+		}
+		if err := pipeline.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", entry.Name(), err)
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+	return pipelines, nil
+}
+
+// LoadPipelineDir 读取 PipelineDir 中的流水线定义并合并到 Pipelines
+func (c *Config) LoadPipelineDir() error {
+	pipelines, err := LoadPipelines(c.PipelineDir)
+	if err != nil {
+		return err
+	}
+	c.MergePipelines(pipelines)
+	return nil
+}
+
+// MergePipelines 将 pipelines 合并到配置中，同名流水线被替换
+func (c *Config) MergePipelines(pipelines []models.Pipeline) {
+	for _, pipeline := range pipelines {
+		replaced := false
+		for i := range c.Pipelines {
+			if c.Pipelines[i].Name == pipeline.Name {
+				c.Pipelines[i] = pipeline
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.Pipelines = append(c.Pipelines, pipeline)
+		}
+	}
+}
+
+// Pipeline 按名称查找流水线
+func (c *Config) Pipeline(name string) (models.Pipeline, bool) {
+	for _, pipeline := range c.Pipelines {
+		if pipeline.Name == name {
+			return pipeline, true
+		}
+	}
+	return models.Pipeline{}, false
+}
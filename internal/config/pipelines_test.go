@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPipelines(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "quick.yaml"), []byte(`
+name: quick
+steps:
+  - name: build-chain
+    path: /setup/build/chain
+    retries: 2
+    retryDelay: 5s
+  - name: liveness
+    method: GET
+    path: /proxy/-1/consensus
+    expectStatus: 200
+`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "lab-cluster.json"),
+		[]byte(`{"name":"lab-cluster","steps":[{"name":"start","path":"/setup/cluster/start"}]}`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o644))
+
+	c := NewConfig()
+	c.PipelineDir = dir
+	assert.NoError(t, c.LoadPipelineDir())
+	assert.Len(t, c.Pipelines, 2)
+
+	quick, ok := c.Pipeline("quick")
+	assert.True(t, ok)
+	assert.Equal(t, 2, quick.Steps[0].Retries)
+	assert.Equal(t, 5*time.Second, quick.Steps[0].RetryDelay)
+	assert.Equal(t, "GET", quick.Steps[1].Method)
+
+	// 同名定义替换内置流水线
+	builtin, _ := c.Pipeline("lab-cluster")
+	assert.Len(t, builtin.Steps, 1)
+
+	_, err := LoadPipelines(filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+}
+
+func TestLoadPipelinesRejectsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bad.yml"),
+		[]byte("name: bad\nsteps:\n  - name: a\n    path: setup\n"), 0o644))
+	_, err := LoadPipelines(dir)
+	assert.ErrorContains(t, err, "bad.yml")
+
+	p := defaultPipeline()
+	assert.NoError(t, p.Validate())
+}
@@ -4,8 +4,36 @@ import (
 	"encoding/json"
 	"github.com/cynic-1/blockchain-teaching-system/internal/models"
 	"github.com/dgraph-io/badger/v3"
+	"strings"
+	"time"
 )
 
+// 非用户数据的键前缀，用户记录直接以用户ID为键
+const (
+	imagePrefix    = "image:"
+	labPrefix      = "lab:"
+	snapshotPrefix = "snapshot:"
+	sessionPrefix  = "labsession:"
+	jobPrefix      = "job:"
+	runPrefix      = "pipelinerun:"
+	quotaPrefix    = "quota:"
+)
+
+// quotaTTL 每日配额计数的保留时长，过期后由 badger 自动删除
+const quotaTTL = 48 * time.Hour
+
+// entityPrefixes 所有非用户数据的键前缀，遍历用户时跳过
+var entityPrefixes = []string{imagePrefix, labPrefix, snapshotPrefix, sessionPrefix, jobPrefix, runPrefix, quotaPrefix}
+
+func isUserKey(key string) bool {
+	for _, prefix := range entityPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
 type Database struct {
 	db *badger.DB
 }
@@ -23,29 +51,303 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-func (d *Database) SaveUser(user *models.User) error {
+// put 将 value 序列化为 JSON 后写入 key
+func (d *Database) put(key string, value interface{}) error {
 	return d.db.Update(func(txn *badger.Txn) error {
-		userBytes, err := json.Marshal(user)
+		valueBytes, err := json.Marshal(value)
 		if err != nil {
 			return err
 		}
-		return txn.Set([]byte(user.ID), userBytes)
+		return txn.Set([]byte(key), valueBytes)
 	})
 }
 
-func (d *Database) GetUser(userID string) (*models.User, error) {
-	var user models.User
-	err := d.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(userID))
+// get 读取 key 并反序列化到 value
+func (d *Database) get(key string, value interface{}) error {
+	return d.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
 		if err != nil {
 			return err
 		}
 		return item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &user)
+			return json.Unmarshal(val, value)
 		})
 	})
-	if err != nil {
+}
+
+// delete 删除 key
+func (d *Database) delete(key string) error {
+	return d.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(key))
+	})
+}
+
+// scan 遍历指定前缀下的所有值
+func (d *Database) scan(prefix string, fn func(val []byte) error) error {
+	return d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			if err := it.Item().Value(fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (d *Database) SaveUser(user *models.User) error {
+	return d.put(user.ID, user)
+}
+
+func (d *Database) GetUser(userID string) (*models.User, error) {
+	var user models.User
+	if err := d.get(userID, &user); err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+func (d *Database) DeleteUser(userID string) error {
+	return d.delete(userID)
+}
+
+func (d *Database) ListUsers() ([]*models.User, error) {
+	users := []*models.User{}
+	err := d.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if !isUserKey(string(it.Item().Key())) {
+				continue
+			}
+			var user models.User
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &user)
+			})
+			if err != nil {
+				return err
+			}
+			users = append(users, &user)
+		}
+		return nil
+	})
+	return users, err
+}
+
+func (d *Database) SaveImage(image *models.Image) error {
+	return d.put(imagePrefix+image.ID, image)
+}
+
+func (d *Database) GetImage(imageID string) (*models.Image, error) {
+	var image models.Image
+	if err := d.get(imagePrefix+imageID, &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func (d *Database) ListImages() ([]*models.Image, error) {
+	images := []*models.Image{}
+	err := d.scan(imagePrefix, func(val []byte) error {
+		var image models.Image
+		if err := json.Unmarshal(val, &image); err != nil {
+			return err
+		}
+		images = append(images, &image)
+		return nil
+	})
+	return images, err
+}
+
+func (d *Database) DeleteImage(imageID string) error {
+	return d.delete(imagePrefix + imageID)
+}
+
+func (d *Database) SaveLab(lab *models.Lab) error {
+	return d.put(labPrefix+lab.ID, lab)
+}
+
+func (d *Database) GetLab(labID string) (*models.Lab, error) {
+	var lab models.Lab
+	if err := d.get(labPrefix+labID, &lab); err != nil {
+		return nil, err
+	}
+	return &lab, nil
+}
+
+func (d *Database) ListLabs() ([]*models.Lab, error) {
+	labs := []*models.Lab{}
+	err := d.scan(labPrefix, func(val []byte) error {
+		var lab models.Lab
+		if err := json.Unmarshal(val, &lab); err != nil {
+			return err
+		}
+		labs = append(labs, &lab)
+		return nil
+	})
+	return labs, err
+}
+
+func (d *Database) DeleteLab(labID string) error {
+	return d.delete(labPrefix + labID)
+}
+
+func snapshotKey(userID, name string) string {
+	return snapshotPrefix + userID + ":" + name
+}
+
+func (d *Database) SaveSnapshot(snapshot *models.Snapshot) error {
+	return d.put(snapshotKey(snapshot.UserID, snapshot.Name), snapshot)
+}
+
+func (d *Database) GetSnapshot(userID, name string) (*models.Snapshot, error) {
+	var snapshot models.Snapshot
+	if err := d.get(snapshotKey(userID, name), &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (d *Database) ListSnapshots(userID string) ([]*models.Snapshot, error) {
+	snapshots := []*models.Snapshot{}
+	err := d.scan(snapshotPrefix+userID+":", func(val []byte) error {
+		var snapshot models.Snapshot
+		if err := json.Unmarshal(val, &snapshot); err != nil {
+			return err
+		}
+		snapshots = append(snapshots, &snapshot)
+		return nil
+	})
+	return snapshots, err
+}
+
+func (d *Database) DeleteSnapshot(userID, name string) error {
+	return d.delete(snapshotKey(userID, name))
+}
+
+func (d *Database) SaveLabSession(session *models.LabSession) error {
+	return d.put(sessionPrefix+session.ID, session)
+}
+
+func (d *Database) ListLabSessions() ([]*models.LabSession, error) {
+	sessions := []*models.LabSession{}
+	err := d.scan(sessionPrefix, func(val []byte) error {
+		var session models.LabSession
+		if err := json.Unmarshal(val, &session); err != nil {
+			return err
+		}
+		sessions = append(sessions, &session)
+		return nil
+	})
+	return sessions, err
+}
+
+func (d *Database) DeleteLabSession(sessionID string) error {
+	return d.delete(sessionPrefix + sessionID)
+}
+
+func (d *Database) SaveJob(job *models.Job) error {
+	return d.put(jobPrefix+job.ID, job)
+}
+
+func (d *Database) GetJob(jobID string) (*models.Job, error) {
+	var job models.Job
+	if err := d.get(jobPrefix+jobID, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (d *Database) ListJobs() ([]*models.Job, error) {
+	jobs := []*models.Job{}
+	err := d.scan(jobPrefix, func(val []byte) error {
+		var job models.Job
+		if err := json.Unmarshal(val, &job); err != nil {
+			return err
+		}
+		jobs = append(jobs, &job)
+		return nil
+	})
+	return jobs, err
+}
+
+func (d *Database) DeleteJob(jobID string) error {
+	return d.delete(jobPrefix + jobID)
+}
+
+func (d *Database) SavePipelineRun(run *models.PipelineRun) error {
+	return d.put(runPrefix+run.ID, run)
+}
+
+func (d *Database) GetPipelineRun(runID string) (*models.PipelineRun, error) {
+	var run models.PipelineRun
+	if err := d.get(runPrefix+runID, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (d *Database) ListPipelineRuns() ([]*models.PipelineRun, error) {
+	runs := []*models.PipelineRun{}
+	err := d.scan(runPrefix, func(val []byte) error {
+		var run models.PipelineRun
+		if err := json.Unmarshal(val, &run); err != nil {
+			return err
+		}
+		runs = append(runs, &run)
+		return nil
+	})
+	return runs, err
+}
+
+// quotaKey 用户某天某操作的次数的键，day 格式为 2006-01-02
+func quotaKey(userID, day, operation string) []byte {
+	return []byte(quotaPrefix + day + ":" + userID + ":" + operation)
+}
+
+// updateQuota 在一个事务中读取次数，update 返回新的次数和是否写入
+func (d *Database) updateQuota(key []byte, update func(count int) (int, bool)) (bool, error) {
+	written := false
+	err := d.db.Update(func(txn *badger.Txn) error {
+		count := 0
+		item, err := txn.Get(key)
+		switch {
+		case err == nil:
+			err = item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &count)
+			})
+			if err != nil {
+				return err
+			}
+		case err != badger.ErrKeyNotFound:
+			return err
+		}
+
+		count, written = update(count)
+		if !written {
+			return nil
+		}
+		value, err := json.Marshal(count)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(key, value).WithTTL(quotaTTL))
+	})
+	return written, err
+}
+
+// TakeQuota 用户当天某操作的次数小于 limit 时加一并返回 true
+func (d *Database) TakeQuota(userID, day, operation string, limit int) (bool, error) {
+	return d.updateQuota(quotaKey(userID, day, operation), func(count int) (int, bool) {
+		return count + 1, count < limit
+	})
+}
+
+// RefundQuota 用户当天某操作的次数大于零时减一
+func (d *Database) RefundQuota(userID, day, operation string) error {
+	_, err := d.updateQuota(quotaKey(userID, day, operation), func(count int) (int, bool) {
+		return count - 1, count > 0
+	})
+	return err
+}
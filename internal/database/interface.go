@@ -7,4 +7,38 @@ type DatabaseInterface interface {
 	Close() error
 	SaveUser(user *models.User) error
 	GetUser(userID string) (*models.User, error)
+	ListUsers() ([]*models.User, error)
+	DeleteUser(userID string) error
+
+	SaveImage(image *models.Image) error
+	GetImage(imageID string) (*models.Image, error)
+	ListImages() ([]*models.Image, error)
+	DeleteImage(imageID string) error
+
+	SaveLab(lab *models.Lab) error
+	GetLab(labID string) (*models.Lab, error)
+	ListLabs() ([]*models.Lab, error)
+	DeleteLab(labID string) error
+
+	SaveSnapshot(snapshot *models.Snapshot) error
+	GetSnapshot(userID, name string) (*models.Snapshot, error)
+	ListSnapshots(userID string) ([]*models.Snapshot, error)
+	DeleteSnapshot(userID, name string) error
+
+	SaveLabSession(session *models.LabSession) error
+	ListLabSessions() ([]*models.LabSession, error)
+	DeleteLabSession(sessionID string) error
+
+	SaveJob(job *models.Job) error
+	GetJob(jobID string) (*models.Job, error)
+	ListJobs() ([]*models.Job, error)
+	DeleteJob(jobID string) error
+
+	SavePipelineRun(run *models.PipelineRun) error
+	GetPipelineRun(runID string) (*models.PipelineRun, error)
+	ListPipelineRuns() ([]*models.PipelineRun, error)
+
+	// 每日配额的使用次数，按用户、日期和操作计数
+	TakeQuota(userID, day, operation string, limit int) (bool, error)
+	RefundQuota(userID, day, operation string) error
 }
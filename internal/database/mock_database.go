@@ -3,15 +3,30 @@ package database
 import (
 	"github.com/cynic-1/blockchain-teaching-system/internal/models"
 	"github.com/dgraph-io/badger/v3"
+	"sort"
 )
 
 type MockDatabase struct {
-	Users map[string]*models.User
+	Users     map[string]*models.User
+	Images    map[string]*models.Image
+	Labs      map[string]*models.Lab
+	Snapshots map[string]map[string]*models.Snapshot // 用户ID -> 快照名 -> 快照
+	Sessions  map[string]*models.LabSession
+	Jobs      map[string]*models.Job
+	Runs      map[string]*models.PipelineRun
+	Quotas    map[string]int // 日期:用户ID:操作 -> 次数
 }
 
 func NewMockDatabase() *MockDatabase {
 	return &MockDatabase{
-		Users: make(map[string]*models.User),
+		Users:     make(map[string]*models.User),
+		Images:    make(map[string]*models.Image),
+		Labs:      make(map[string]*models.Lab),
+		Snapshots: make(map[string]map[string]*models.Snapshot),
+		Sessions:  make(map[string]*models.LabSession),
+		Jobs:      make(map[string]*models.Job),
+		Runs:      make(map[string]*models.PipelineRun),
+		Quotas:    make(map[string]int),
 	}
 }
 
@@ -32,3 +47,186 @@ func (m *MockDatabase) GetUser(userID string) (*models.User, error) {
 	}
 	return user, nil
 }
+
+func (m *MockDatabase) DeleteUser(userID string) error {
+	delete(m.Users, userID)
+	return nil
+}
+
+func (m *MockDatabase) ListUsers() ([]*models.User, error) {
+	users := []*models.User{}
+	for _, user := range m.Users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (m *MockDatabase) SaveImage(image *models.Image) error {
+	m.Images[image.ID] = image
+	return nil
+}
+
+func (m *MockDatabase) GetImage(imageID string) (*models.Image, error) {
+	image, exists := m.Images[imageID]
+	if !exists {
+		return nil, badger.ErrKeyNotFound
+	}
+	return image, nil
+}
+
+func (m *MockDatabase) ListImages() ([]*models.Image, error) {
+	images := []*models.Image{}
+	for _, image := range m.Images {
+		images = append(images, image)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].ID < images[j].ID })
+	return images, nil
+}
+
+func (m *MockDatabase) DeleteImage(imageID string) error {
+	delete(m.Images, imageID)
+	return nil
+}
+
+func (m *MockDatabase) SaveLab(lab *models.Lab) error {
+	m.Labs[lab.ID] = lab
+	return nil
+}
+
+func (m *MockDatabase) GetLab(labID string) (*models.Lab, error) {
+	lab, exists := m.Labs[labID]
+	if !exists {
+		return nil, badger.ErrKeyNotFound
+	}
+	return lab, nil
+}
+
+func (m *MockDatabase) ListLabs() ([]*models.Lab, error) {
+	labs := []*models.Lab{}
+	for _, lab := range m.Labs {
+		labs = append(labs, lab)
+	}
+	sort.Slice(labs, func(i, j int) bool { return labs[i].ID < labs[j].ID })
+	return labs, nil
+}
+
+func (m *MockDatabase) DeleteLab(labID string) error {
+	delete(m.Labs, labID)
+	return nil
+}
+
+func (m *MockDatabase) SaveSnapshot(snapshot *models.Snapshot) error {
+	if m.Snapshots[snapshot.UserID] == nil {
+		m.Snapshots[snapshot.UserID] = make(map[string]*models.Snapshot)
+	}
+	m.Snapshots[snapshot.UserID][snapshot.Name] = snapshot
+	return nil
+}
+
+func (m *MockDatabase) GetSnapshot(userID, name string) (*models.Snapshot, error) {
+	snapshot, exists := m.Snapshots[userID][name]
+	if !exists {
+		return nil, badger.ErrKeyNotFound
+	}
+	return snapshot, nil
+}
+
+func (m *MockDatabase) ListSnapshots(userID string) ([]*models.Snapshot, error) {
+	snapshots := []*models.Snapshot{}
+	for _, snapshot := range m.Snapshots[userID] {
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots, nil
+}
+
+func (m *MockDatabase) DeleteSnapshot(userID, name string) error {
+	delete(m.Snapshots[userID], name)
+	return nil
+}
+
+func (m *MockDatabase) SaveLabSession(session *models.LabSession) error {
+	m.Sessions[session.ID] = session
+	return nil
+}
+
+func (m *MockDatabase) ListLabSessions() ([]*models.LabSession, error) {
+	sessions := []*models.LabSession{}
+	for _, session := range m.Sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions, nil
+}
+
+func (m *MockDatabase) DeleteLabSession(sessionID string) error {
+	delete(m.Sessions, sessionID)
+	return nil
+}
+
+func (m *MockDatabase) SaveJob(job *models.Job) error {
+	m.Jobs[job.ID] = job
+	return nil
+}
+
+func (m *MockDatabase) GetJob(jobID string) (*models.Job, error) {
+	job, exists := m.Jobs[jobID]
+	if !exists {
+		return nil, badger.ErrKeyNotFound
+	}
+	return job, nil
+}
+
+func (m *MockDatabase) ListJobs() ([]*models.Job, error) {
+	jobs := []*models.Job{}
+	for _, job := range m.Jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+func (m *MockDatabase) DeleteJob(jobID string) error {
+	delete(m.Jobs, jobID)
+	return nil
+}
+
+func (m *MockDatabase) SavePipelineRun(run *models.PipelineRun) error {
+	m.Runs[run.ID] = run
+	return nil
+}
+
+func (m *MockDatabase) GetPipelineRun(runID string) (*models.PipelineRun, error) {
+	run, exists := m.Runs[runID]
+	if !exists {
+		return nil, badger.ErrKeyNotFound
+	}
+	return run, nil
+}
+
+func (m *MockDatabase) ListPipelineRuns() ([]*models.PipelineRun, error) {
+	runs := []*models.PipelineRun{}
+	for _, run := range m.Runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID < runs[j].ID })
+	return runs, nil
+}
+
+func (m *MockDatabase) TakeQuota(userID, day, operation string, limit int) (bool, error) {
+	key := day + ":" + userID + ":" + operation
+	if m.Quotas[key] >= limit {
+		return false, nil
+	}
+	m.Quotas[key]++
+	return true, nil
+}
+
+func (m *MockDatabase) RefundQuota(userID, day, operation string) error {
+	key := day + ":" + userID + ":" + operation
+	if m.Quotas[key] > 0 {
+		m.Quotas[key]--
+	}
+	return nil
+}
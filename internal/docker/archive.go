@@ -0,0 +1,19 @@
+package docker
+
+import (
+	"context"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// CopyFromContainer 以 tar 格式导出容器内的文件或目录，调用方负责关闭返回的 reader
+func (dm *DockerManager) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, error) {
+	reader, _, err := dm.client.CopyFromContainer(ctx, containerID, srcPath)
+	return reader, err
+}
+
+// CopyToContainer 将 tar 内容解压到容器内的目录，容器未运行时同样可用
+func (dm *DockerManager) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader) error {
+	return dm.client.CopyToContainer(ctx, containerID, dstPath, content, container.CopyToContainerOptions{})
+}
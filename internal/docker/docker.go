@@ -2,12 +2,34 @@ package docker
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
 )
 
 type DockerManager struct {
 	client *client.Client
+
+	// 多主机模式下的主机名和容量权重
+	name   string
+	weight int
+	// hosts 多主机模式下的全部主机，第一个为默认主机（即管理器自身），单主机时为空
+	hosts []*DockerManager
+
+	// proxyAddr 访问容器发布端口时使用的宿主机地址，为空时直接访问容器 IP
+	proxyAddr string
+	// http 调用容器内 chain-proxy 的客户端，为空时使用 http.DefaultClient
+	http *http.Client
 }
 
 func NewDockerManager(apiVersion string) (*DockerManager, error) {
@@ -15,20 +37,216 @@ func NewDockerManager(apiVersion string) (*DockerManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &DockerManager{client: cli}, nil
+	return &DockerManager{client: cli, http: &http.Client{}}, nil
+}
+
+// 平台创建的容器上使用的标签
+const (
+	LabelOwner = "bts.user"  // 容器所属用户ID
+	LabelImage = "bts.image" // 镜像目录中的镜像ID
+	LabelPool  = "bts.pool"  // 由预热池创建的容器
+)
+
+// OwnerError 同名的网络或数据卷已存在且属于其他用户
+type OwnerError struct {
+	Resource string // network 或 volume
+	Name     string
+	Owner    string // 已有资源的 LabelOwner 标签，没有标签时为空
+}
+
+func (e *OwnerError) Error() string {
+	return fmt.Sprintf("%s %s belongs to another user", e.Resource, e.Name)
+}
+
+// checkOwner labels 带有 LabelOwner 时，要求已有资源属于同一用户
+func checkOwner(resource, name string, existing, labels map[string]string) error {
+	owner, ok := labels[LabelOwner]
+	if !ok || existing[LabelOwner] == owner {
+		return nil
+	}
+	return &OwnerError{Resource: resource, Name: name, Owner: existing[LabelOwner]}
+}
+
+// ContainerSummary 容器的简要信息
+type ContainerSummary struct {
+	ID     string
+	Labels map[string]string
+	State  string
+}
+
+// safeNamePattern 可直接用于 Docker 资源名和文件名的用户ID，注册时校验
+var safeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+// IsSafeName 判断用户ID能否直接用作 Docker 资源名和目录名
+func IsSafeName(userID string) bool {
+	return safeNamePattern.MatchString(userID)
+}
+
+// ResourceName 根据前缀和用户ID生成合法的 Docker 资源（网络、卷等）名称。
+// 不符合 IsSafeName 的旧用户ID使用 "_" 加ID的哈希，合法ID不以 "_" 开头，因此不同用户的名称不会相同
+func ResourceName(prefix, userID string) string {
+	if IsSafeName(userID) {
+		return prefix + userID
+	}
+	sum := sha256.Sum256([]byte(userID))
+	return prefix + "_" + hex.EncodeToString(sum[:16])
+}
+
+// ContainerOptions 创建容器的参数
+type ContainerOptions struct {
+	Image    string
+	Cmd      []string
+	Labels   map[string]string
+	CPUs     float64 // CPU 核数限制，0 表示不限制
+	MemoryMB int64   // 内存限制（MB），0 表示不限制
+	// PortBindings 容器端口到宿主机端口的映射，如 {"8080": 20000}
+	PortBindings map[string]int
+	// Network 容器创建时连接的唯一网络，为空时使用默认 bridge 网络
+	Network string
+	// Volume 挂载到 VolumeTarget 的命名卷，为空时不挂载
+	Volume       string
+	VolumeTarget string
+	// HealthCmd 容器内执行的健康检查 shell 命令，为空时沿用镜像的 HEALTHCHECK
+	HealthCmd      string
+	HealthInterval time.Duration
+	HealthRetries  int
+	// RestartPolicy Docker 重启策略，如 "unless-stopped"，为空时不自动重启
+	RestartPolicy string
+}
+
+// healthConfig 根据参数生成健康检查配置，未设置命令时返回 nil
+func (opts ContainerOptions) healthConfig() *container.HealthConfig {
+	if opts.HealthCmd == "" {
+		return nil
+	}
+	return &container.HealthConfig{
+		Test:     []string{"CMD-SHELL", opts.HealthCmd},
+		Interval: opts.HealthInterval,
+		Retries:  opts.HealthRetries,
+	}
 }
 
 func (dm *DockerManager) CreateContainer(ctx context.Context, image string, cmd []string) (string, error) {
+	return dm.CreateContainerWithOptions(ctx, ContainerOptions{Image: image, Cmd: cmd})
+}
+
+// CreateContainerWithOptions 按参数创建容器，并应用资源限制
+func (dm *DockerManager) CreateContainerWithOptions(ctx context.Context, opts ContainerOptions) (string, error) {
+	exposedPorts := nat.PortSet{}
+	portMap := nat.PortMap{}
+	for containerPort, hostPort := range opts.PortBindings {
+		port := nat.Port(containerPort + "/tcp")
+		exposedPorts[port] = struct{}{}
+		portMap[port] = []nat.PortBinding{{HostPort: strconv.Itoa(hostPort)}}
+	}
+
+	hostConfig := &container.HostConfig{
+		NetworkMode:  container.NetworkMode(opts.Network),
+		PortBindings: portMap,
+		RestartPolicy: container.RestartPolicy{
+			Name: container.RestartPolicyMode(opts.RestartPolicy),
+		},
+		Resources: container.Resources{
+			NanoCPUs: int64(opts.CPUs * 1e9),
+			Memory:   opts.MemoryMB * 1024 * 1024,
+		},
+	}
+	if opts.Volume != "" {
+		hostConfig.Mounts = []mount.Mount{{
+			Type:   mount.TypeVolume,
+			Source: opts.Volume,
+			Target: opts.VolumeTarget,
+		}}
+	}
 	resp, err := dm.client.ContainerCreate(ctx, &container.Config{
-		Image: image,
-		Cmd:   cmd,
-	}, nil, nil, nil, "")
+		Image:        opts.Image,
+		Cmd:          opts.Cmd,
+		Labels:       opts.Labels,
+		ExposedPorts: exposedPorts,
+		Healthcheck:  opts.healthConfig(),
+	}, hostConfig, nil, nil, "")
 	if err != nil {
 		return "", err
 	}
 	return resp.ID, nil
 }
 
+// ContainerExists 检查容器是否仍然存在，容器不存在时不返回错误
+func (dm *DockerManager) ContainerExists(ctx context.Context, containerID string) (bool, error) {
+	if containerID == "" {
+		return false, nil
+	}
+	_, err := dm.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ListContainers 列出带有指定标签（如 "bts.pool" 或 "bts.pool=true"）的所有容器，包括已停止的容器
+func (dm *DockerManager) ListContainers(ctx context.Context, label string) ([]ContainerSummary, error) {
+	containers, err := dm.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]ContainerSummary, 0, len(containers))
+	for _, c := range containers {
+		summaries = append(summaries, ContainerSummary{ID: c.ID, Labels: c.Labels, State: c.State})
+	}
+	return summaries, nil
+}
+
+// ContainerHealth 容器运行状态和健康检查结果
+type ContainerHealth struct {
+	State         string `json:"state"`
+	Health        string `json:"health"` // starting、healthy、unhealthy，未配置健康检查时为空
+	FailingStreak int    `json:"failingStreak"`
+	LastOutput    string `json:"lastOutput,omitempty"`
+	RestartCount  int    `json:"restartCount"`
+}
+
+// InspectHealth 返回容器的运行状态和最近一次健康检查结果
+func (dm *DockerManager) InspectHealth(ctx context.Context, containerID string) (*ContainerHealth, error) {
+	info, err := dm.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	health := &ContainerHealth{State: info.State.Status, RestartCount: info.RestartCount}
+	if h := info.State.Health; h != nil {
+		health.Health = h.Status
+		health.FailingStreak = h.FailingStreak
+		if len(h.Log) > 0 {
+			health.LastOutput = h.Log[len(h.Log)-1].Output
+		}
+	}
+	return health, nil
+}
+
+// ListUnhealthyContainers 列出带有指定标签且健康检查失败的容器
+func (dm *DockerManager) ListUnhealthyContainers(ctx context.Context, label string) ([]ContainerSummary, error) {
+	containers, err := dm.client.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", label), filters.Arg("health", "unhealthy")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]ContainerSummary, 0, len(containers))
+	for _, c := range containers {
+		summaries = append(summaries, ContainerSummary{ID: c.ID, Labels: c.Labels, State: c.State})
+	}
+	return summaries, nil
+}
+
+func (dm *DockerManager) RestartContainer(ctx context.Context, containerID string) error {
+	return dm.client.ContainerRestart(ctx, containerID, container.StopOptions{})
+}
+
 func (dm *DockerManager) StartContainer(ctx context.Context, containerID string) error {
 	return dm.client.ContainerStart(ctx, containerID, container.StartOptions{})
 }
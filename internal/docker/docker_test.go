@@ -123,79 +123,66 @@ func TestDockerManager(t *testing.T) {
 		t.Fatalf("Container no longer exists after starting: %v", err)
 	}
 
-	// 执行命令
-	t.Log("Executing command in container")
-	result, err := dm.ExecuteShellCommand(containerID, []string{"echo", "hello world!"})
-	if err != nil {
-		t.Logf("Error executing command: %v", err)
-	} else {
-		assert.Contains(t, string(result), "Hello, World!")
-	}
-
-	result, err = dm.CreateLocalClusterFactory(containerID, 4, 9999, 4)
+	result, err := dm.CreateLocalClusterFactory(ctx, containerID, 4, 9999, 4)
 	if err != nil {
 		t.Logf("Error creating local cluster: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.ResetWorkingDirectory(containerID)
+	result, err = dm.ResetWorkingDirectory(ctx, containerID)
 	if err != nil {
 		t.Logf("Error reseting workDir: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.ExecuteShellCommand(containerID, []string{"ls", "-l"})
-	if err != nil {
-		t.Logf("Error executing command: %v", err)
-	} else {
-		t.Log(result)
-	}
-
-	result, err = dm.MakeValidatorKeysAndStakeQuotas(containerID)
+	result, err = dm.MakeValidatorKeysAndStakeQuotas(ctx, containerID)
 	if err != nil {
 		t.Logf("Error generating validator keys and stake quotas: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.MakeLocalAddresses(containerID)
+	result, err = dm.MakeLocalAddresses(ctx, containerID)
 	if err != nil {
 		t.Logf("Error making local addresses: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.WriteGenesisFiles(containerID)
+	result, err = dm.WriteGenesisFiles(ctx, containerID)
 	if err != nil {
 		t.Logf("Error writing genesis files: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.BuildBlockchainBinary(containerID)
+	// 编译耗时较长，不使用默认超时
+	buildCtx, cancelBuild := context.WithTimeout(ctx, 10*time.Minute)
+	result, err = dm.BuildBlockchainBinary(buildCtx, containerID)
+	cancelBuild()
 	if err != nil {
 		t.Logf("Error building blockchain binary: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.CreateCluster(containerID)
+	result, err = dm.CreateCluster(ctx, containerID)
 	if err != nil {
 		t.Logf("Error creating new cluster: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.StartCluster(containerID)
+	result, err = dm.StartCluster(ctx, containerID)
 	if err != nil {
 		t.Logf("Error starting cluster: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.GetConsensusStatus(containerID)
+	result, err = dm.GetConsensusStatus(ctx, containerID)
 	if err != nil {
 		t.Logf("Error getting consensus status: %v", err)
 	} else {
@@ -204,21 +191,21 @@ func TestDockerManager(t *testing.T) {
 
 	time.Sleep(5 * time.Second)
 
-	result, err = dm.GetConsensusStatus(containerID)
+	result, err = dm.GetConsensusStatus(ctx, containerID)
 	if err != nil {
 		t.Logf("Error getting consensus status: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.GetTxpoolStatus(containerID)
+	result, err = dm.GetTxpoolStatus(ctx, containerID)
 	if err != nil {
 		t.Logf("Error getting txpool status: %v", err)
 	} else {
 		t.Log(result)
 	}
 
-	result, err = dm.StopCluster(containerID)
+	result, err = dm.StopCluster(ctx, containerID)
 	if err != nil {
 		t.Logf("Error stoping cluster: %v", err)
 	} else {
@@ -239,3 +226,44 @@ func TestDockerManager(t *testing.T) {
 		t.Logf("Error removing container: %v", err)
 	}
 }
+
+func TestContainerOptionsHealthConfig(t *testing.T) {
+	assert.Nil(t, ContainerOptions{}.healthConfig())
+
+	opts := ContainerOptions{
+		HealthCmd:      "curl -s -o /dev/null http://localhost:8080/ || exit 1",
+		HealthInterval: 30 * time.Second,
+		HealthRetries:  3,
+	}
+	health := opts.healthConfig()
+	assert.Equal(t, []string{"CMD-SHELL", opts.HealthCmd}, health.Test)
+	assert.Equal(t, 30*time.Second, health.Interval)
+	assert.Equal(t, 3, health.Retries)
+}
+
+func TestCheckOwner(t *testing.T) {
+	labels := map[string]string{LabelOwner: "alice"}
+	assert.NoError(t, checkOwner("volume", "vol-alice", map[string]string{LabelOwner: "alice"}, labels))
+	// 不带 LabelOwner 时不检查，如共享网络
+	assert.NoError(t, checkOwner("network", "shared", map[string]string{LabelOwner: "bob"}, nil))
+
+	err := checkOwner("volume", "vol-bob", map[string]string{LabelOwner: "bob"}, labels)
+	ownerErr, ok := err.(*OwnerError)
+	assert.True(t, ok)
+	assert.Equal(t, "bob", ownerErr.Owner)
+	// 没有所属标签的已有资源也不能被用户占用
+	assert.Error(t, checkOwner("volume", "vol-x", nil, labels))
+}
+
+func TestResourceName(t *testing.T) {
+	assert.Equal(t, "bts-net-alice.1", ResourceName("bts-net-", "alice.1"))
+	// 替换非法字符会使不同用户得到同一名称，因此旧用户ID使用哈希
+	assert.NotEqual(t, ResourceName("bts-net-", "a b"), ResourceName("bts-net-", "a/b"))
+	assert.NotEqual(t, ResourceName("bts-net-", "a b"), ResourceName("bts-net-", "a-b"))
+	assert.True(t, strings.HasPrefix(ResourceName("", ".."), "_"))
+
+	assert.False(t, IsSafeName(""))
+	assert.False(t, IsSafeName("a:b"))
+	assert.False(t, IsSafeName(".hidden"))
+	assert.False(t, IsSafeName(strings.Repeat("a", 65)))
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"context"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"strings"
+	"time"
+)
+
+// ContainerEvent 容器状态变化事件
+type ContainerEvent struct {
+	ContainerID string    `json:"containerID"`
+	Owner       string    `json:"owner,omitempty"` // 来自容器标签
+	Action      string    `json:"action"`          // start、die、oom、stop、destroy、restart、health_status
+	Detail      string    `json:"detail,omitempty"`
+	ExitCode    string    `json:"exitCode,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// 关注的容器事件
+var watchedActions = []string{"start", "die", "oom", "stop", "destroy", "restart", "health_status"}
+
+// WatchEvents 订阅平台容器（带 LabelOwner 标签）的事件，对每个事件调用 onEvent，直到 ctx 结束或事件流出错
+func (dm *DockerManager) WatchEvents(ctx context.Context, onEvent func(ContainerEvent)) error {
+	args := filters.NewArgs(filters.Arg("type", string(events.ContainerEventType)), filters.Arg("label", LabelOwner))
+	for _, action := range watchedActions {
+		args.Add("event", action)
+	}
+	messages, errs := dm.client.Events(ctx, events.ListOptions{Filters: args})
+	for {
+		select {
+		case msg := <-messages:
+			onEvent(toContainerEvent(msg))
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+// toContainerEvent 转换 Docker 事件，健康检查事件的 action 形如 "health_status: unhealthy"
+func toContainerEvent(msg events.Message) ContainerEvent {
+	action, detail, _ := strings.Cut(string(msg.Action), ":")
+	return ContainerEvent{
+		ContainerID: msg.Actor.ID,
+		Owner:       msg.Actor.Attributes[LabelOwner],
+		Action:      action,
+		Detail:      strings.TrimSpace(detail),
+		ExitCode:    msg.Actor.Attributes["exitCode"],
+		Time:        time.Unix(0, msg.TimeNano),
+	}
+}
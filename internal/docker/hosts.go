@@ -0,0 +1,123 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"net/http"
+)
+
+// HostConfig 一台 Docker 主机的连接配置
+type HostConfig struct {
+	Name     string
+	Endpoint string // 如 tcp://10.0.0.2:2375
+	Weight   int    // 容量权重，按 容器数/权重 计算负载，小于 1 时按 1 计算
+	// ProxyAddress 平台访问该主机上容器发布端口的地址，为空时直接访问容器 IP（平台与容器网络互通时）
+	ProxyAddress string
+}
+
+// NewMultiHostManager 创建管理多台 Docker 主机的管理器，返回的管理器本身操作第一台主机。
+// 未配置主机时等同于 NewDockerManager，使用环境变量中的 Docker 主机
+func NewMultiHostManager(apiVersion string, hosts []HostConfig) (*DockerManager, error) {
+	if len(hosts) == 0 {
+		return NewDockerManager(apiVersion)
+	}
+
+	managers := make([]*DockerManager, 0, len(hosts))
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if host.Name == "" || seen[host.Name] {
+			return nil, fmt.Errorf("invalid or duplicate docker host name %q", host.Name)
+		}
+		seen[host.Name] = true
+
+		cli, err := client.NewClientWithOpts(client.WithHost(host.Endpoint), client.WithVersion(apiVersion))
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %v", host.Name, err)
+		}
+		managers = append(managers, &DockerManager{
+			client:    cli,
+			name:      host.Name,
+			weight:    host.Weight,
+			proxyAddr: host.ProxyAddress,
+			http:      &http.Client{},
+		})
+	}
+	managers[0].hosts = managers
+	return managers[0], nil
+}
+
+// Name 返回主机名，单主机模式下为空
+func (dm *DockerManager) Name() string {
+	return dm.name
+}
+
+// Hosts 返回管理的全部主机，单主机模式下只有自身
+func (dm *DockerManager) Hosts() []*DockerManager {
+	if len(dm.hosts) == 0 {
+		return []*DockerManager{dm}
+	}
+	return dm.hosts
+}
+
+// ErrUnknownHost 主机不在当前配置中
+var ErrUnknownHost = errors.New("docker host is not configured")
+
+// Host 返回指定名称的主机，名称为空（单主机时期创建的用户）时返回默认主机。
+// 主机已从配置中移除时返回 ErrUnknownHost，而不是在默认主机上操作同名资源
+func (dm *DockerManager) Host(name string) (*DockerManager, error) {
+	if name == "" || name == dm.name {
+		return dm, nil
+	}
+	for _, host := range dm.hosts {
+		if host.name == name {
+			return host, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownHost, name)
+}
+
+// Load 返回主机上平台容器的数量除以容量权重
+func (dm *DockerManager) Load(ctx context.Context) (float64, error) {
+	containers, err := dm.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", LabelOwner)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	weight := dm.weight
+	if weight < 1 {
+		weight = 1
+	}
+	return float64(len(containers)) / float64(weight), nil
+}
+
+// Schedule 选择负载最低的主机用于创建新容器，跳过无法访问的主机
+func (dm *DockerManager) Schedule(ctx context.Context) (*DockerManager, error) {
+	hosts := dm.Hosts()
+	if len(hosts) == 1 {
+		return hosts[0], nil
+	}
+
+	var best *DockerManager
+	var bestLoad float64
+	var errs []error
+	for _, host := range hosts {
+		load, err := host.Load(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("docker host %s: %v", host.name, err))
+			continue
+		}
+		if best == nil || load < bestLoad {
+			best, bestLoad = host, load
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no docker host available: %v", errors.Join(errs...))
+	}
+	return best, nil
+}
@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDockerHost 模拟只实现容器列表接口的 Docker 端点
+func fakeDockerHost(t *testing.T, containers int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/json") {
+			http.NotFound(w, r)
+			return
+		}
+		list := make([]types.Container, containers)
+		for i := range list {
+			list[i] = types.Container{ID: "c", Labels: map[string]string{LabelOwner: "student"}}
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func endpoint(srv *httptest.Server) string {
+	return "tcp://" + srv.Listener.Addr().String()
+}
+
+func TestScheduleLeastLoadedHost(t *testing.T) {
+	busy := fakeDockerHost(t, 2)
+	large := fakeDockerHost(t, 4)
+	down := fakeDockerHost(t, 0)
+	down.Close()
+
+	dm, err := NewMultiHostManager("1.41", []HostConfig{
+		{Name: "lab-a", Endpoint: endpoint(busy), Weight: 1},
+		{Name: "lab-b", Endpoint: endpoint(large), Weight: 4},
+		{Name: "lab-c", Endpoint: endpoint(down), Weight: 8},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, dm.Hosts(), 3)
+
+	// lab-a 负载 2，lab-b 负载 1，lab-c 不可达被跳过
+	host, err := dm.Schedule(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "lab-b", host.Name())
+
+	host, err = dm.Host("lab-b")
+	assert.NoError(t, err)
+	assert.Equal(t, "lab-b", host.Name())
+	// 未记录主机的用户使用默认主机，已移除的主机返回错误
+	host, err = dm.Host("")
+	assert.NoError(t, err)
+	assert.Equal(t, "lab-a", host.Name())
+	_, err = dm.Host("lab-d")
+	assert.ErrorIs(t, err, ErrUnknownHost)
+}
+
+func TestScheduleNoHostAvailable(t *testing.T) {
+	down := fakeDockerHost(t, 0)
+	down.Close()
+	other := fakeDockerHost(t, 0)
+	other.Close()
+
+	dm, err := NewMultiHostManager("1.41", []HostConfig{
+		{Name: "lab-a", Endpoint: endpoint(down)},
+		{Name: "lab-b", Endpoint: endpoint(other)},
+	})
+	assert.NoError(t, err)
+	_, err = dm.Schedule(context.Background())
+	assert.Error(t, err)
+
+	_, err = NewMultiHostManager("1.41", []HostConfig{{Name: "lab-a"}, {Name: "lab-a"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+)
+
+// PullProgress 镜像拉取过程中的一条进度信息
+type PullProgress struct {
+	ID       string `json:"id,omitempty"`
+	Status   string `json:"status"`
+	Progress string `json:"progress,omitempty"`
+}
+
+// pullMessage Docker 拉取镜像时返回的 JSON 消息
+type pullMessage struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Progress    string `json:"progress"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// PullImage 拉取镜像，每条进度信息回调 onProgress（可为 nil）
+func (dm *DockerManager) PullImage(ctx context.Context, ref string, onProgress func(PullProgress)) error {
+	reader, err := dm.client.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg pullMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("error reading pull progress: %v", err)
+		}
+		if msg.ErrorDetail != nil {
+			return fmt.Errorf("pull %s failed: %s", ref, msg.ErrorDetail.Message)
+		}
+		if onProgress != nil {
+			onProgress(PullProgress{ID: msg.ID, Status: msg.Status, Progress: msg.Progress})
+		}
+	}
+}
+
+// VerifyImage 检查镜像已存在于本地，digest 非空时校验镜像的 RepoDigests 中包含该 digest
+func (dm *DockerManager) VerifyImage(ctx context.Context, ref, digest string) error {
+	inspect, _, err := dm.client.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if digest == "" {
+		return nil
+	}
+	for _, repoDigest := range inspect.RepoDigests {
+		if strings.HasSuffix(repoDigest, "@"+digest) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %s does not match digest %s", ref, digest)
+}
+
+// EnsureImage 镜像不存在时按需拉取，并校验 digest
+func (dm *DockerManager) EnsureImage(ctx context.Context, ref, digest string, onProgress func(PullProgress)) error {
+	err := dm.VerifyImage(ctx, ref, digest)
+	if err == nil {
+		return nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return err
+	}
+	if err := dm.PullImage(ctx, ref, onProgress); err != nil {
+		return err
+	}
+	return dm.VerifyImage(ctx, ref, digest)
+}
+
+// CommitContainer 将容器当前的文件系统提交为镜像 ref，返回镜像ID
+func (dm *DockerManager) CommitContainer(ctx context.Context, containerID, ref string) (string, error) {
+	resp, err := dm.client.ContainerCommit(ctx, containerID, container.CommitOptions{
+		Reference: ref,
+		Pause:     true,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// RemoveImage 删除镜像，镜像不存在时不返回错误
+func (dm *DockerManager) RemoveImage(ctx context.Context, ref string) error {
+	_, err := dm.client.ImageRemove(ctx, ref, image.RemoveOptions{Force: true, PruneChildren: true})
+	if err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
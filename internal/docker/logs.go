@@ -0,0 +1,28 @@
+package docker
+
+import (
+	"context"
+	"github.com/docker/docker/api/types/container"
+	"io"
+)
+
+// LogOptions 读取容器日志的参数，Since、Until 可为 RFC3339 时间或相对时长（如 "10m"）
+type LogOptions struct {
+	Tail   string // 从末尾读取的行数，"all" 表示全部
+	Since  string
+	Until  string
+	Follow bool
+}
+
+// ContainerLogs 返回容器的日志流，stdout 和 stderr 以 Docker 多路复用格式交织，需用 stdcopy 分离
+func (dm *DockerManager) ContainerLogs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error) {
+	return dm.client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       opts.Tail,
+		Since:      opts.Since,
+		Until:      opts.Until,
+		Follow:     opts.Follow,
+		Timestamps: true,
+	})
+}
@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+)
+
+// EnsureNetwork 创建用户自定义的 bridge 网络，网络已存在时直接返回。
+// labels 带有 LabelOwner 时，已有的网络属于其他用户则返回 *OwnerError
+func (dm *DockerManager) EnsureNetwork(ctx context.Context, name string, labels map[string]string) error {
+	existing, err := dm.client.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err == nil {
+		return checkOwner("network", name, existing.Labels, labels)
+	}
+	if !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	_, err = dm.client.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver: "bridge",
+		Labels: labels,
+	})
+	// 并发创建同名网络时按已有网络检查所属用户
+	if errdefs.IsConflict(err) {
+		return dm.EnsureNetwork(ctx, name, labels)
+	}
+	return err
+}
+
+// ConnectNetwork 将容器（ID 或名称）连接到网络，已连接时直接返回
+func (dm *DockerManager) ConnectNetwork(ctx context.Context, name, containerID string) error {
+	info, err := dm.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if info.NetworkSettings != nil {
+		if _, ok := info.NetworkSettings.Networks[name]; ok {
+			return nil
+		}
+	}
+	return dm.client.NetworkConnect(ctx, name, containerID, nil)
+}
+
+// DisconnectNetwork 将容器从网络断开，容器或网络不存在时不返回错误
+func (dm *DockerManager) DisconnectNetwork(ctx context.Context, name, containerID string) error {
+	err := dm.client.NetworkDisconnect(ctx, name, containerID, true)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// RemoveNetwork 删除网络，网络不存在时不返回错误
+func (dm *DockerManager) RemoveNetwork(ctx context.Context, name string) error {
+	err := dm.client.NetworkRemove(ctx, name)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+)
+
+const (
+	// ChainProxyPort 容器内 chain-proxy 服务监听的端口
+	ChainProxyPort = "8080"
+	// DefaultChainTimeout 未指定超时的 chain-proxy 请求的超时时间
+	DefaultChainTimeout = 30 * time.Second
+	// maxChainResponseSize chain-proxy 响应体的大小上限
+	maxChainResponseSize = 32 << 20
+	// livenessTimeout 从宿主机探测 chain-proxy 的超时时间
+	livenessTimeout = 5 * time.Second
+)
+
+var (
+	// ErrContainerNotRunning 容器未运行，chain-proxy 不可用
+	ErrContainerNotRunning = errors.New("container is not running")
+	// ErrChainUnreachable 找不到容器内 chain-proxy 的地址
+	ErrChainUnreachable = errors.New("chain-proxy is unreachable")
+	// ErrChainResponseTooLarge chain-proxy 的响应体超过大小上限
+	ErrChainResponseTooLarge = errors.New("chain-proxy response too large")
+)
+
+// ChainRequest 发往容器内 chain-proxy 的 HTTP 请求
+type ChainRequest struct {
+	Method  string
+	Path    string // 可带查询参数，与 Query 合并
+	Query   url.Values
+	Header  http.Header
+	Body    []byte
+	Timeout time.Duration // 为 0 时沿用 ctx 的截止时间，ctx 也没有截止时间时使用 DefaultChainTimeout
+}
+
+// ChainResponse chain-proxy 的响应，保留上游状态码
+type ChainResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// IsJSON 响应体是否为 JSON。chain-proxy 部分接口不设置 Content-Type，因此同时检查内容
+func (r *ChainResponse) IsJSON() bool {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return true
+	}
+	return json.Valid(bytes.TrimSpace(r.Body))
+}
+
+// Decode 将 JSON 响应体解码到 v
+func (r *ChainResponse) Decode(v interface{}) error {
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("error decoding chain-proxy response: %w", err)
+	}
+	return nil
+}
+
+// Value 返回响应体的解析结果：JSON 响应解码为对应的值，其他响应为去掉首尾空白的文本
+func (r *ChainResponse) Value() interface{} {
+	return ChainValue(r.Body)
+}
+
+// Err 上游返回非 2xx 时返回 *ChainStatusError
+func (r *ChainResponse) Err() error {
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return &ChainStatusError{StatusCode: r.StatusCode, Body: string(r.Body)}
+	}
+	return nil
+}
+
+// ChainValue 将 chain-proxy 的输出解析为 JSON 值，非 JSON 输出按去掉首尾空白的文本返回
+func ChainValue(body []byte) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		return v
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// ChainStatusError chain-proxy 返回非 2xx 状态码
+type ChainStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ChainStatusError) Error() string {
+	return fmt.Sprintf("chain-proxy returned %d: %s", e.StatusCode, e.Body)
+}
+
+// chainAddress 返回容器内 chain-proxy 的地址。配置了 proxyAddr 的主机通过宿主机上发布的端口访问，
+// 否则直接访问容器在平台网络中的 IP
+func (dm *DockerManager) chainAddress(ctx context.Context, containerID string) (string, error) {
+	info, err := dm.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	if info.State == nil || !info.State.Running {
+		return "", fmt.Errorf("container %s: %w", containerID, ErrContainerNotRunning)
+	}
+	if info.NetworkSettings == nil {
+		return "", fmt.Errorf("container %s has no network: %w", containerID, ErrChainUnreachable)
+	}
+
+	if dm.proxyAddr != "" {
+		for _, binding := range info.NetworkSettings.Ports[nat.Port(ChainProxyPort+"/tcp")] {
+			if binding.HostPort != "" {
+				return net.JoinHostPort(dm.proxyAddr, binding.HostPort), nil
+			}
+		}
+		return "", fmt.Errorf("container %s has no published port %s: %w", containerID, ChainProxyPort, ErrChainUnreachable)
+	}
+
+	// 按网络名排序，容器连接多个网络时结果稳定
+	names := make([]string, 0, len(info.NetworkSettings.Networks))
+	for name := range info.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ip := info.NetworkSettings.Networks[name].IPAddress; ip != "" {
+			return net.JoinHostPort(ip, ChainProxyPort), nil
+		}
+	}
+	return "", fmt.Errorf("container %s has no IP address: %w", containerID, ErrChainUnreachable)
+}
+
+// chainContext 按 ChainRequest.Timeout 为请求设置超时，未指定时沿用 ctx 的截止时间，
+// ctx 也没有截止时间时使用 DefaultChainTimeout
+func chainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); !ok && timeout <= 0 {
+		timeout = DefaultChainTimeout
+	}
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// openChainRequest 发送请求并返回响应，调用方负责关闭响应体
+func (dm *DockerManager) openChainRequest(ctx context.Context, containerID string, req ChainRequest) (*http.Response, error) {
+	addr, err := dm.chainAddress(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	for key, values := range req.Query {
+		for _, v := range values {
+			query.Add(key, v)
+		}
+	}
+	target := url.URL{Scheme: "http", Host: addr, Path: u.Path, RawQuery: query.Encode()}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range req.Header {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+	if len(req.Body) > 0 && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	return dm.httpClient().Do(httpReq)
+}
+
+// DoChainRequest 直接通过 HTTP 调用容器内的 chain-proxy，上游的非 2xx 响应不视为错误
+func (dm *DockerManager) DoChainRequest(ctx context.Context, containerID string, req ChainRequest) (*ChainResponse, error) {
+	ctx, cancel := chainContext(ctx, req.Timeout)
+	defer cancel()
+
+	resp, err := dm.openChainRequest(ctx, containerID, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// 多读一个字节，以区分恰好达到上限和超过上限
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxChainResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading chain-proxy response: %w", err)
+	}
+	if len(body) > maxChainResponseSize {
+		return nil, ErrChainResponseTooLarge
+	}
+	return &ChainResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// CheckLiveness 从宿主机通过 HTTP 探测容器内的 chain-proxy，服务有响应即视为存活，
+// 不依赖镜像中的 curl 等工具
+func (dm *DockerManager) CheckLiveness(ctx context.Context, containerID string) error {
+	ctx, cancel := chainContext(ctx, livenessTimeout)
+	defer cancel()
+
+	resp, err := dm.openChainRequest(ctx, containerID, ChainRequest{Method: http.MethodGet, Path: "/"})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// StreamChainRequest 调用 chain-proxy，响应体到达时逐段写入 w，返回上游状态码。
+// 用于编译等输出持续较长时间的接口
+func (dm *DockerManager) StreamChainRequest(ctx context.Context, containerID string, req ChainRequest, w io.Writer) (int, error) {
+	ctx, cancel := chainContext(ctx, req.Timeout)
+	defer cancel()
+
+	resp, err := dm.openChainRequest(ctx, containerID, req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return resp.StatusCode, fmt.Errorf("error reading chain-proxy response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// ChainTarget 返回容器内 chain-proxy 的基础 URL，供反向代理使用
+func (dm *DockerManager) ChainTarget(ctx context.Context, containerID string) (*url.URL, error) {
+	addr, err := dm.chainAddress(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "http", Host: addr}, nil
+}
+
+// ChainTransport 返回访问 chain-proxy 使用的 Transport
+func (dm *DockerManager) ChainTransport() http.RoundTripper {
+	if transport := dm.httpClient().Transport; transport != nil {
+		return transport
+	}
+	return http.DefaultTransport
+}
+
+func (dm *DockerManager) httpClient() *http.Client {
+	if dm.http != nil {
+		return dm.http
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,195 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeInspectHost 模拟只实现容器详情接口的 Docker 端点，容器的 8080 端口发布到 hostPort
+func fakeInspectHost(t *testing.T, running bool, hostPort string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/c1/json") {
+			http.NotFound(w, r)
+			return
+		}
+		info := types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: "c1", State: &types.ContainerState{Running: running}},
+			NetworkSettings: &types.NetworkSettings{NetworkSettingsBase: types.NetworkSettingsBase{
+				Ports: nat.PortMap{"8080/tcp": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: hostPort}}},
+			}},
+			Config: &container.Config{},
+		}
+		_ = json.NewEncoder(w).Encode(info)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDoChainRequest(t *testing.T) {
+	chain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"query":       r.URL.RawQuery,
+			"trace":       r.Header.Get("X-Trace"),
+			"contentType": r.Header.Get("Content-Type"),
+			"body":        string(body),
+		})
+	}))
+	defer chain.Close()
+	_, chainPort, _ := net.SplitHostPort(chain.Listener.Addr().String())
+
+	dm, err := NewMultiHostManager("1.41", []HostConfig{
+		{Name: "lab-a", Endpoint: endpoint(fakeInspectHost(t, true, chainPort)), ProxyAddress: "127.0.0.1"},
+	})
+	assert.NoError(t, err)
+
+	resp, err := dm.DoChainRequest(context.Background(), "c1", ChainRequest{
+		Method: "PUT",
+		Path:   "/proxy/-1/blocks?from=1",
+		Query:  map[string][]string{"to": {"5"}},
+		Header: http.Header{"X-Trace": {"abc"}},
+		Body:   []byte(`{"a":1}`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var echoed map[string]string
+	assert.NoError(t, json.Unmarshal(resp.Body, &echoed))
+	assert.Equal(t, map[string]string{
+		"method":      "PUT",
+		"path":        "/proxy/-1/blocks",
+		"query":       "from=1&to=5",
+		"trace":       "abc",
+		"contentType": "application/json",
+		"body":        `{"a":1}`,
+	}, echoed)
+
+	// 上游状态码原样保留，sendRequest 将其作为 ChainStatusError 返回
+	resp, err = dm.DoChainRequest(context.Background(), "c1", ChainRequest{Path: "/missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	_, err = dm.sendRequest(context.Background(), "c1", "GET", "/missing", "")
+	var statusErr *ChainStatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestDoChainRequestTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	_, slowPort, _ := net.SplitHostPort(slow.Listener.Addr().String())
+
+	dm, err := NewMultiHostManager("1.41", []HostConfig{
+		{Name: "lab-a", Endpoint: endpoint(fakeInspectHost(t, true, slowPort)), ProxyAddress: "127.0.0.1"},
+	})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = dm.DoChainRequest(context.Background(), "c1", ChainRequest{Path: "/", Timeout: 50 * time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 未指定超时时沿用 ctx 的截止时间
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = dm.sendRequest(ctx, "c1", "POST", "/setup/build/chain", "")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// ctx 取消（客户端断开）时请求立即结束
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = dm.DoChainRequest(ctx, "c1", ChainRequest{Path: "/"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDoChainRequestStoppedContainer(t *testing.T) {
+	dm, err := NewMultiHostManager("1.41", []HostConfig{
+		{Name: "lab-a", Endpoint: endpoint(fakeInspectHost(t, false, "1")), ProxyAddress: "127.0.0.1"},
+	})
+	assert.NoError(t, err)
+
+	_, err = dm.DoChainRequest(context.Background(), "c1", ChainRequest{Path: "/"})
+	assert.ErrorIs(t, err, ErrContainerNotRunning)
+}
+
+func TestDoChainRequestTooLarge(t *testing.T) {
+	chain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := maxChainResponseSize
+		if r.URL.Path == "/large" {
+			size++
+		}
+		_, _ = w.Write([]byte(strings.Repeat("a", size)))
+	}))
+	defer chain.Close()
+	_, chainPort, _ := net.SplitHostPort(chain.Listener.Addr().String())
+
+	dm, err := NewMultiHostManager("1.41", []HostConfig{
+		{Name: "lab-a", Endpoint: endpoint(fakeInspectHost(t, true, chainPort)), ProxyAddress: "127.0.0.1"},
+	})
+	assert.NoError(t, err)
+
+	// 恰好达到上限时完整返回，超过上限时返回错误而不是截断
+	resp, err := dm.DoChainRequest(context.Background(), "c1", ChainRequest{Path: "/limit"})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Body, maxChainResponseSize)
+	_, err = dm.DoChainRequest(context.Background(), "c1", ChainRequest{Path: "/large"})
+	assert.ErrorIs(t, err, ErrChainResponseTooLarge)
+}
+
+func TestCheckLiveness(t *testing.T) {
+	// 任何响应（包括 404）都说明 chain-proxy 存活
+	chain := httptest.NewServer(http.NotFoundHandler())
+	defer chain.Close()
+	_, chainPort, _ := net.SplitHostPort(chain.Listener.Addr().String())
+	dm, err := NewMultiHostManager("1.41", []HostConfig{
+		{Name: "lab-a", Endpoint: endpoint(fakeInspectHost(t, true, chainPort)), ProxyAddress: "127.0.0.1"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, dm.CheckLiveness(context.Background(), "c1"))
+
+	// 端口无人监听时探测失败
+	chain.Close()
+	assert.Error(t, dm.CheckLiveness(context.Background(), "c1"))
+}
+
+func TestChainResponse(t *testing.T) {
+	resp := &ChainResponse{StatusCode: http.StatusOK, Header: http.Header{}, Body: []byte(`{"height":3}`)}
+	assert.True(t, resp.IsJSON())
+	assert.Equal(t, map[string]interface{}{"height": float64(3)}, resp.Value())
+	var status struct {
+		Height int `json:"height"`
+	}
+	assert.NoError(t, resp.Decode(&status))
+	assert.Equal(t, 3, status.Height)
+	assert.NoError(t, resp.Err())
+
+	resp = &ChainResponse{StatusCode: http.StatusInternalServerError, Header: http.Header{}, Body: []byte("cluster not created\n")}
+	assert.False(t, resp.IsJSON())
+	assert.Equal(t, "cluster not created", resp.Value())
+	assert.Error(t, resp.Decode(&status))
+	var statusErr *ChainStatusError
+	assert.ErrorAs(t, resp.Err(), &statusErr)
+	assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+}
@@ -0,0 +1,181 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOperationNotFound = errors.New("operation not found")
+
+// 操作状态
+const (
+	OperationQueued    = "queued"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+// Operation 排队执行的 Docker 操作
+type Operation struct {
+	ID         string      `json:"id"`
+	UserID     string      `json:"userID"`
+	Type       string      `json:"type"`
+	Status     string      `json:"status"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	StartedAt  time.Time   `json:"startedAt,omitempty"`
+	FinishedAt time.Time   `json:"finishedAt,omitempty"`
+}
+
+// OperationFunc 队列中执行的操作，返回结果和错误
+type OperationFunc func(ctx context.Context) (interface{}, error)
+
+type queuedOperation struct {
+	op   *Operation
+	fn   OperationFunc
+	done chan struct{}
+}
+
+// Queue 以固定数量的 worker 执行 Docker 操作，在用户之间轮转调度，
+// 避免单个用户的大量请求占满 worker
+type Queue struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	pending   map[string][]*queuedOperation // 用户ID -> 待执行操作
+	users     []string                      // 有待执行操作的用户，按轮转顺序排列
+	ops       map[string]*queuedOperation
+	retention time.Duration
+}
+
+// NewQueue 创建队列并启动 workers 个 worker，已完成的操作保留 retention 时长供查询
+func NewQueue(workers int, retention time.Duration) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &Queue{
+		pending:   make(map[string][]*queuedOperation),
+		ops:       make(map[string]*queuedOperation),
+		retention: retention,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+// Submit 提交操作，立即返回操作ID
+func (q *Queue) Submit(userID, opType string, fn OperationFunc) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	qo := &queuedOperation{
+		op: &Operation{
+			ID:        hex.EncodeToString(b),
+			UserID:    userID,
+			Type:      opType,
+			Status:    OperationQueued,
+			CreatedAt: time.Now(),
+		},
+		fn:   fn,
+		done: make(chan struct{}),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	q.ops[qo.op.ID] = qo
+	if len(q.pending[userID]) == 0 {
+		q.users = append(q.users, userID)
+	}
+	q.pending[userID] = append(q.pending[userID], qo)
+	q.cond.Signal()
+	return qo.op.ID
+}
+
+// Get 返回操作当前状态的副本
+func (q *Queue) Get(id string) (Operation, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	qo, ok := q.ops[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return *qo.op, true
+}
+
+// Wait 等待操作完成并返回其最终状态，ctx 结束时返回 ctx 的错误（操作仍会继续执行）
+func (q *Queue) Wait(ctx context.Context, id string) (Operation, error) {
+	q.mu.Lock()
+	qo, ok := q.ops[id]
+	q.mu.Unlock()
+	if !ok {
+		return Operation{}, ErrOperationNotFound
+	}
+
+	select {
+	case <-qo.done:
+		op, _ := q.Get(id)
+		return op, nil
+	case <-ctx.Done():
+		return Operation{}, ctx.Err()
+	}
+}
+
+// next 按用户轮转取出下一个待执行的操作，没有操作时阻塞
+func (q *Queue) next() *queuedOperation {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.users) == 0 {
+		q.cond.Wait()
+	}
+
+	userID := q.users[0]
+	q.users = q.users[1:]
+	qo := q.pending[userID][0]
+	q.pending[userID] = q.pending[userID][1:]
+	if len(q.pending[userID]) > 0 {
+		// 该用户仍有操作，排到队尾等待下一轮
+		q.users = append(q.users, userID)
+	} else {
+		delete(q.pending, userID)
+	}
+
+	qo.op.Status = OperationRunning
+	qo.op.StartedAt = time.Now()
+	return qo
+}
+
+func (q *Queue) worker() {
+	for {
+		qo := q.next()
+		result, err := qo.fn(context.Background())
+
+		q.mu.Lock()
+		qo.op.Result = result
+		qo.op.FinishedAt = time.Now()
+		if err != nil {
+			qo.op.Status = OperationFailed
+			qo.op.Error = err.Error()
+		} else {
+			qo.op.Status = OperationSucceeded
+		}
+		q.mu.Unlock()
+		close(qo.done)
+	}
+}
+
+// expire 删除超过保留时长的已完成操作，调用方需持有锁
+func (q *Queue) expire() {
+	cutoff := time.Now().Add(-q.retention)
+	for id, qo := range q.ops {
+		finished := qo.op.Status == OperationSucceeded || qo.op.Status == OperationFailed
+		if finished && qo.op.FinishedAt.Before(cutoff) {
+			delete(q.ops, id)
+		}
+	}
+}
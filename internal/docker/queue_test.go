@@ -0,0 +1,63 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueRoundRobinAcrossUsers(t *testing.T) {
+	q := NewQueue(1, time.Hour)
+
+	// 先占住唯一的 worker，使后续操作全部排队
+	gate := make(chan struct{})
+	q.Submit("gate", "block", func(ctx context.Context) (interface{}, error) {
+		<-gate
+		return nil, nil
+	})
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) OperationFunc {
+		return func(ctx context.Context) (interface{}, error) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return name, nil
+		}
+	}
+	q.Submit("alice", "create", record("alice-1"))
+	q.Submit("alice", "start", record("alice-2"))
+	q.Submit("alice", "stop", record("alice-3"))
+	last := q.Submit("bob", "create", record("bob-1"))
+	close(gate)
+
+	op, err := q.Wait(context.Background(), last)
+	assert.NoError(t, err)
+	assert.Equal(t, OperationSucceeded, op.Status)
+	assert.Equal(t, "bob-1", op.Result)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"alice-1", "bob-1"}, order[:2])
+}
+
+func TestQueueRecordsFailure(t *testing.T) {
+	q := NewQueue(2, time.Hour)
+	id := q.Submit("alice", "start", func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("daemon unavailable")
+	})
+
+	op, err := q.Wait(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, OperationFailed, op.Status)
+	assert.Equal(t, "daemon unavailable", op.Error)
+	assert.Equal(t, "alice", op.UserID)
+
+	_, err = q.Wait(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrOperationNotFound)
+}
@@ -1,71 +1,44 @@
 package docker
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/pkg/stdcopy"
-	"strings"
 )
 
-func (dm *DockerManager) sendRequest(containerID, method, path string, body string) (string, error) {
-	// 预设
-	containerPort := "8080"
-
-	// 创建执行命令
-	cmd := []string{"curl", "-s", "-X", method, "-H", "Content-Type: application/json"}
-	if body != "" {
-		cmd = append(cmd, "-d", body)
-	}
-	cmd = append(cmd, fmt.Sprintf("http://localhost:%s%s", containerPort, path))
-
-	fmt.Println(cmd)
-	// 使用Docker客户端执行命令
-	execConfig := container.ExecOptions{
-		Cmd:          cmd,
-		AttachStdout: true,
-		AttachStderr: true,
-	}
-
-	ctx := context.Background()
-	execID, err := dm.client.ContainerExecCreate(ctx, containerID, execConfig)
-	if err != nil {
-		return "", err
-	}
+// chain-proxy 的集群搭建和状态查询接口
+const (
+	SetupFactoryPath = "/setup/new/factory"      // 创建本地集群工厂
+	SetupAddrsPath   = "/setup/genesis/addrs"    // 创建本地点和主题地址
+	SetupKeysPath    = "/setup/genesis/random"   // 创建验证者密钥和权益配额
+	SetupGenesisPath = "/setup/genesis/template" // 写入创世文件
+	SetupClusterPath = "/setup/new/cluster"      // 创建集群
+	SetupBuildPath   = "/setup/build/chain"      // 构建区块链二进制文件
+	SetupResetPath   = "/setup/reset/workdir"    // 重置工作目录
+	ClusterStartPath = "/setup/cluster/start"    // 启动集群
+	ClusterStopPath  = "/setup/cluster/stop"     // 停止集群
+	ConsensusPath    = "/proxy/-1/consensus"     // 共识状态
+	TxpoolPath       = "/proxy/-1/txpool"        // 交易池状态
+	BlockHeightPath  = "/proxy/-1/blocks/height" // 特定高度的区块，后接 /<高度>
+)
 
-	resp, err := dm.client.ContainerExecAttach(ctx, execID.ID, container.ExecStartOptions{})
+// sendRequest 调用容器内的 chain-proxy 并返回响应体，上游返回非 2xx 时返回 *ChainStatusError。
+// 超时和取消由 ctx 决定，ctx 没有截止时间时使用 DefaultChainTimeout
+func (dm *DockerManager) sendRequest(ctx context.Context, containerID, method, path string, body string) (string, error) {
+	resp, err := dm.DoChainRequest(ctx, containerID, ChainRequest{
+		Method: method,
+		Path:   path,
+		Body:   []byte(body),
+	})
 	if err != nil {
 		return "", err
 	}
-	defer resp.Close()
-
-	// 使用 stdcopy.StdCopy 来分离 STDOUT 和 STDERR
-	var outBuf, errBuf bytes.Buffer
-	_, err = stdcopy.StdCopy(&outBuf, &errBuf, resp.Reader)
-	if err != nil {
-		return string([]byte("")), fmt.Errorf("error reading exec output: %v", err)
-	}
-
-	// 合并 STDOUT 和 STDERR
-	output := outBuf.String()
-	errOutput := errBuf.String()
-
-	// 如果有错误输出，添加到输出中
-	if errOutput != "" {
-		output += "\nError output: " + errOutput
-	}
-
-	// 检查 curl 命令是否成功执行
-	if strings.Contains(output, "curl: (") {
-		return "", fmt.Errorf("curl command failed: %s", output)
-	}
-
-	return output, nil
+	return string(resp.Body), resp.Err()
 }
 
-func (dm *DockerManager) SendRequest(containerID, path, body string) (string, error) {
-	return dm.sendRequest(containerID, "POST", path, body)
+// SendRequest 以指定方法调用 chain-proxy，状态查询等只读接口使用 GET
+func (dm *DockerManager) SendRequest(ctx context.Context, containerID, method, path, body string) (string, error) {
+	return dm.sendRequest(ctx, containerID, method, path, body)
 }
 
 // deprecated
@@ -127,68 +100,73 @@ func (dm *DockerManager) SendRequest(containerID, path, body string) (string, er
 //
 //	return output, nil
 //}
-//
-//// 获取共识状态
-//func (dm *DockerManager) GetConsensusStatus(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "GET", "/proxy/-1/consensus", nil)
-//}
-//
-//// 获取交易池状态
-//func (dm *DockerManager) GetTxpoolStatus(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "GET", "/proxy/-1/txpool", nil)
-//}
-//
-//// 获取特定高度的区块
-//func (dm *DockerManager) GetBlockAtHeight(containerID string, height int) (string, error) {
-//	return dm.sendRequest(containerID, "GET", fmt.Sprintf("/proxy/-1/blocks/height/%d", height), nil)
-//}
-//
-//// 创建本地集群工厂
-//func (dm *DockerManager) CreateLocalClusterFactory(containerID string, nodeCount, stakeQuota, windowSize int) (string, error) {
-//	body := map[string]int{
-//		"nodeCount":  nodeCount,
-//		"stakeQuota": stakeQuota,
-//		"windowSize": windowSize,
-//	}
-//	return dm.sendRequest(containerID, "POST", "/setup/new/factory", body)
-//}
-//
-//// 创建本地点和主题地址
-//func (dm *DockerManager) MakeLocalAddresses(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "POST", "/setup/genesis/addrs", nil)
-//}
-//
-//// 创建验证者密钥和权益配额
-//func (dm *DockerManager) MakeValidatorKeysAndStakeQuotas(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "POST", "/setup/genesis/random", nil)
-//}
-//
-//// 写入创世文件
-//func (dm *DockerManager) WriteGenesisFiles(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "POST", "/setup/genesis/template", nil)
-//}
-//
-//// 创建名为cluster_template的集群
-//func (dm *DockerManager) CreateCluster(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "POST", "/setup/new/cluster", nil)
-//}
-//
-//// 构建区块链二进制文件
-//func (dm *DockerManager) BuildBlockchainBinary(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "POST", "/setup/build/chain", nil)
-//}
-//
-//// 查看每个节点的工作目录
-//func (dm *DockerManager) ResetWorkingDirectory(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "POST", "/setup/reset/workdir", nil)
-//}
-//
-//// 启动集群
-//func (dm *DockerManager) StartCluster(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "POST", "/setup/cluster/start", nil)
-//}
-//
-//// 停止集群
-//func (dm *DockerManager) StopCluster(containerID string) (string, error) {
-//	return dm.sendRequest(containerID, "POST", "/setup/cluster/stop", nil)
-//}
+
+// 获取共识状态
+func (dm *DockerManager) GetConsensusStatus(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "GET", ConsensusPath, "")
+}
+
+// 获取交易池状态
+func (dm *DockerManager) GetTxpoolStatus(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "GET", TxpoolPath, "")
+}
+
+// 获取特定高度的区块
+func (dm *DockerManager) GetBlockAtHeight(ctx context.Context, containerID string, height int) (string, error) {
+	return dm.sendRequest(ctx, containerID, "GET", fmt.Sprintf("%s/%d", BlockHeightPath, height), "")
+}
+
+// 创建本地集群工厂
+func (dm *DockerManager) CreateLocalClusterFactory(ctx context.Context, containerID string, nodeCount, stakeQuota, windowSize int) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", SetupFactoryPath, string(ClusterFactoryBody(nodeCount, stakeQuota, windowSize)))
+}
+
+// ClusterFactoryBody 创建集群工厂接口的请求体
+func ClusterFactoryBody(nodeCount, stakeQuota, windowSize int) []byte {
+	body, _ := json.Marshal(map[string]int{
+		"nodeCount":  nodeCount,
+		"stakeQuota": stakeQuota,
+		"windowSize": windowSize,
+	})
+	return body
+}
+
+// 创建本地点和主题地址
+func (dm *DockerManager) MakeLocalAddresses(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", SetupAddrsPath, "")
+}
+
+// 创建验证者密钥和权益配额
+func (dm *DockerManager) MakeValidatorKeysAndStakeQuotas(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", SetupKeysPath, "")
+}
+
+// 写入创世文件
+func (dm *DockerManager) WriteGenesisFiles(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", SetupGenesisPath, "")
+}
+
+// 创建名为cluster_template的集群
+func (dm *DockerManager) CreateCluster(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", SetupClusterPath, "")
+}
+
+// 构建区块链二进制文件
+func (dm *DockerManager) BuildBlockchainBinary(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", SetupBuildPath, "")
+}
+
+// 查看每个节点的工作目录
+func (dm *DockerManager) ResetWorkingDirectory(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", SetupResetPath, "")
+}
+
+// 启动集群
+func (dm *DockerManager) StartCluster(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", ClusterStartPath, "")
+}
+
+// 停止集群
+func (dm *DockerManager) StopCluster(ctx context.Context, containerID string) (string, error) {
+	return dm.sendRequest(ctx, containerID, "POST", ClusterStopPath, "")
+}
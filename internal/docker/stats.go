@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/docker/docker/api/types/container"
+	"time"
+)
+
+// ResourceUsage 容器某一时刻的资源使用情况
+type ResourceUsage struct {
+	Time          time.Time `json:"time"`
+	CPUPercent    float64   `json:"cpuPercent"` // 相对单核的百分比，多核时可超过 100
+	MemoryBytes   uint64    `json:"memoryBytes"`
+	MemoryLimit   uint64    `json:"memoryLimit"`
+	MemoryPercent float64   `json:"memoryPercent"`
+	NetworkRx     uint64    `json:"networkRx"`
+	NetworkTx     uint64    `json:"networkTx"`
+	PIDs          uint64    `json:"pids"`
+}
+
+// ContainerUsage 读取容器当前的资源使用情况。Docker 需要采样两次计算 CPU 使用率，调用约耗时 1 秒
+func (dm *DockerManager) ContainerUsage(ctx context.Context, containerID string) (*ResourceUsage, error) {
+	resp, err := dm.client.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	usage := resourceUsage(&stats)
+	return &usage, nil
+}
+
+// resourceUsage 按 docker stats 的方式计算 CPU 和内存使用率
+func resourceUsage(stats *container.StatsResponse) ResourceUsage {
+	usage := ResourceUsage{
+		Time:        stats.Read,
+		MemoryLimit: stats.MemoryStats.Limit,
+		PIDs:        stats.PidsStats.Current,
+	}
+
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		usage.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	// 与 docker stats 一致，不计入可回收的页缓存（cgroup v2 为 inactive_file，v1 为 total_inactive_file）
+	usage.MemoryBytes = stats.MemoryStats.Usage
+	inactive, ok := stats.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		inactive = stats.MemoryStats.Stats["total_inactive_file"]
+	}
+	if inactive < usage.MemoryBytes {
+		usage.MemoryBytes -= inactive
+	}
+	if usage.MemoryLimit > 0 {
+		usage.MemoryPercent = float64(usage.MemoryBytes) / float64(usage.MemoryLimit) * 100
+	}
+
+	for _, network := range stats.Networks {
+		usage.NetworkRx += network.RxBytes
+		usage.NetworkTx += network.TxBytes
+	}
+	return usage
+}
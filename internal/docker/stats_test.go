@@ -0,0 +1,34 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceUsage(t *testing.T) {
+	var stats container.StatsResponse
+	stats.CPUStats.CPUUsage.TotalUsage = 3_000_000
+	stats.CPUStats.SystemUsage = 20_000_000
+	stats.CPUStats.OnlineCPUs = 4
+	stats.PreCPUStats.CPUUsage.TotalUsage = 1_000_000
+	stats.PreCPUStats.SystemUsage = 10_000_000
+	stats.MemoryStats.Usage = 300 << 20
+	stats.MemoryStats.Limit = 1 << 30
+	stats.MemoryStats.Stats = map[string]uint64{"inactive_file": 44 << 20}
+	stats.Networks = map[string]container.NetworkStats{
+		"eth0": {RxBytes: 100, TxBytes: 10},
+		"eth1": {RxBytes: 50, TxBytes: 5},
+	}
+
+	usage := resourceUsage(&stats)
+	assert.InDelta(t, 80.0, usage.CPUPercent, 0.001)
+	assert.Equal(t, uint64(256<<20), usage.MemoryBytes)
+	assert.InDelta(t, 25.0, usage.MemoryPercent, 0.001)
+	assert.Equal(t, uint64(150), usage.NetworkRx)
+	assert.Equal(t, uint64(15), usage.NetworkTx)
+
+	// 首次采样没有上一次的数据时不计算 CPU 使用率
+	assert.Zero(t, resourceUsage(&container.StatsResponse{}).CPUPercent)
+}
@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"sync"
+	"time"
+)
+
+// Docker 没有终止 exec 进程的接口，exec 启动时将容器内的 PID 写入 pid 文件，终止时据此发送信号
+const (
+	execPidDir = "/tmp"
+	// execWrapper 记录 PID 后以 exec 替换为目标命令，参数为 pid 文件和目标命令
+	execWrapper = `echo $$ > "$1"; shift; exec "$@"`
+	// execKiller 终止 pid 文件中进程所在的会话（包括 shell 启动的前台作业），参数为 pid 文件
+	execKiller = `pid=$(cat "$1" 2>/dev/null) || exit 0; pkill -KILL -s "$pid" 2>/dev/null || kill -KILL -- -"$pid" "$pid" 2>/dev/null; rm -f "$1"`
+	// killTimeout 终止 exec 进程的超时，调用方的 ctx 此时通常已取消
+	killTimeout = 10 * time.Second
+)
+
+// Terminal 容器内带 TTY 的交互式 exec 会话。TTY 模式下 stdout 和 stderr 合并输出，无需 stdcopy 分离
+type Terminal struct {
+	dm          *DockerManager
+	containerID string
+	execID      string
+	pidFile     string
+	conn        types.HijackedResponse
+	closeOnce   sync.Once
+}
+
+// OpenTerminal 在容器内以 TTY 模式启动 cmd（如 /bin/sh），返回可读写的终端会话。
+// ctx 取消或调用 Close 时终止 exec 进程
+func (dm *DockerManager) OpenTerminal(ctx context.Context, containerID string, cmd []string) (*Terminal, error) {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	pidFile := execPidDir + "/.bts-exec-" + hex.EncodeToString(b)
+
+	execID, err := dm.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          append([]string{"sh", "-c", execWrapper, "sh", pidFile}, cmd...),
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dm.client.ContainerExecAttach(ctx, execID.ID, container.ExecStartOptions{Tty: true})
+	if err != nil {
+		return nil, err
+	}
+	t := &Terminal{dm: dm, containerID: containerID, execID: execID.ID, pidFile: pidFile, conn: conn}
+	go func() {
+		<-ctx.Done()
+		t.Close()
+	}()
+	return t, nil
+}
+
+// Read 读取终端输出
+func (t *Terminal) Read(p []byte) (int, error) {
+	return t.conn.Reader.Read(p)
+}
+
+// Write 向终端写入输入
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.conn.Conn.Write(p)
+}
+
+// Resize 调整终端窗口大小
+func (t *Terminal) Resize(ctx context.Context, rows, cols uint) error {
+	return t.dm.client.ContainerExecResize(ctx, t.execID, container.ResizeOptions{Height: rows, Width: cols})
+}
+
+// Close 关闭终端连接并终止仍在运行的 exec 进程，可重复调用
+func (t *Terminal) Close() {
+	t.closeOnce.Do(func() {
+		t.conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+		defer cancel()
+		_ = t.dm.killExec(ctx, t.containerID, t.execID, t.pidFile)
+	})
+}
+
+// killExec 在 exec 进程仍在运行时通过 pid 文件终止它
+func (dm *DockerManager) killExec(ctx context.Context, containerID, execID, pidFile string) error {
+	info, err := dm.client.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return err
+	}
+	if !info.Running {
+		// 进程已退出，仅清理 pid 文件
+		return dm.runDetached(ctx, containerID, []string{"rm", "-f", pidFile})
+	}
+	return dm.runDetached(ctx, containerID, []string{"sh", "-c", execKiller, "sh", pidFile})
+}
+
+// runDetached 在容器内执行不需要输出的命令
+func (dm *DockerManager) runDetached(ctx context.Context, containerID string, cmd []string) error {
+	execID, err := dm.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{Cmd: cmd})
+	if err != nil {
+		return err
+	}
+	return dm.client.ContainerExecStart(ctx, execID.ID, container.ExecStartOptions{Detach: true})
+}
@@ -0,0 +1,30 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+// EnsureVolume 创建命名卷，同名卷已存在时 Docker 直接返回已有的卷。
+// labels 带有 LabelOwner 时，已有的卷属于其他用户则返回 *OwnerError
+func (dm *DockerManager) EnsureVolume(ctx context.Context, name string, labels map[string]string) error {
+	vol, err := dm.client.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Labels: labels,
+	})
+	if err != nil {
+		return err
+	}
+	return checkOwner("volume", name, vol.Labels, labels)
+}
+
+// RemoveVolume 删除命名卷，卷不存在时不返回错误
+func (dm *DockerManager) RemoveVolume(ctx context.Context, name string) error {
+	err := dm.client.VolumeRemove(ctx, name, false)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
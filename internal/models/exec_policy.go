@@ -0,0 +1,162 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// 执行策略拒绝请求时的错误码
+const (
+	ExecErrPathNotAllowed   = "path_not_allowed"
+	ExecErrMethodNotAllowed = "method_not_allowed"
+	ExecErrInvalidBody      = "invalid_body"
+	ExecErrHeaderNotAllowed = "header_not_allowed"
+)
+
+// ExecPolicyError 请求不符合执行策略
+type ExecPolicyError struct {
+	Code    string
+	Message string
+}
+
+func (e *ExecPolicyError) Error() string {
+	return e.Message
+}
+
+// ExecRule 允许转发到 chain-proxy 的一个接口。Path 按 "/" 分段匹配，"*" 匹配任意一段；
+// Body 为请求体 JSON 对象允许的字段及类型（number、string、boolean、object、array），
+// 为空时不允许请求体
+type ExecRule struct {
+	Path     string            `json:"path"`
+	Methods  []string          `json:"methods"`
+	Body     map[string]string `json:"body,omitempty"`
+	Required []string          `json:"required,omitempty"`
+}
+
+// ExecPolicy 按顺序匹配的规则列表，路径匹配的第一条规则生效
+type ExecPolicy []ExecRule
+
+// Check 检查请求是否被策略允许
+func (p ExecPolicy) Check(method, requestPath, body string) error {
+	requestPath, _, _ = strings.Cut(requestPath, "?")
+	if !strings.HasPrefix(requestPath, "/") || path.Clean(requestPath) != requestPath {
+		return &ExecPolicyError{ExecErrPathNotAllowed, fmt.Sprintf("invalid path %q", requestPath)}
+	}
+
+	for _, rule := range p {
+		if !rule.matchPath(requestPath) {
+			continue
+		}
+		if !rule.allowsMethod(method) {
+			return &ExecPolicyError{ExecErrMethodNotAllowed, fmt.Sprintf("method %s is not allowed for %s", method, requestPath)}
+		}
+		return rule.checkBody(body)
+	}
+	return &ExecPolicyError{ExecErrPathNotAllowed, fmt.Sprintf("path %s is not allowed", requestPath)}
+}
+
+func (r ExecRule) matchPath(requestPath string) bool {
+	pattern := strings.Split(r.Path, "/")
+	segments := strings.Split(requestPath, "/")
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r ExecRule) allowsMethod(method string) bool {
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r ExecRule) checkBody(body string) error {
+	if strings.TrimSpace(body) == "" {
+		if len(r.Required) > 0 {
+			return &ExecPolicyError{ExecErrInvalidBody, fmt.Sprintf("body is required, fields: %s", strings.Join(r.Required, ", "))}
+		}
+		return nil
+	}
+	if r.Body == nil {
+		return &ExecPolicyError{ExecErrInvalidBody, "body is not allowed"}
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &fields); err != nil {
+		return &ExecPolicyError{ExecErrInvalidBody, "body must be a JSON object"}
+	}
+	for name, value := range fields {
+		expected, ok := r.Body[name]
+		if !ok {
+			return &ExecPolicyError{ExecErrInvalidBody, fmt.Sprintf("unknown field %q", name)}
+		}
+		if jsonType(value) != expected {
+			return &ExecPolicyError{ExecErrInvalidBody, fmt.Sprintf("field %q must be %s", name, expected)}
+		}
+	}
+	for _, name := range r.Required {
+		if _, ok := fields[name]; !ok {
+			return &ExecPolicyError{ExecErrInvalidBody, fmt.Sprintf("missing field %q", name)}
+		}
+	}
+	return nil
+}
+
+// jsonType 返回 JSON 值的类型名
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return "null"
+	}
+}
+
+// CheckHeaders 检查请求头是否都在允许列表中，请求头名称不区分大小写
+func CheckHeaders(allowed []string, header http.Header) error {
+	for name := range header {
+		if !headerAllowed(allowed, name) {
+			return &ExecPolicyError{ExecErrHeaderNotAllowed, fmt.Sprintf("header %s is not allowed", name)}
+		}
+	}
+	return nil
+}
+
+// FilterHeaders 返回只保留允许列表中请求头的副本，用于透传代理丢弃浏览器等客户端自动附加的请求头
+func FilterHeaders(allowed []string, header http.Header) http.Header {
+	filtered := make(http.Header)
+	for name, values := range header {
+		if headerAllowed(allowed, name) {
+			filtered[name] = append([]string(nil), values...)
+		}
+	}
+	return filtered
+}
+
+func headerAllowed(allowed []string, name string) bool {
+	for _, a := range allowed {
+		if http.CanonicalHeaderKey(a) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
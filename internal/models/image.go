@@ -0,0 +1,29 @@
+package models
+
+// ResourceProfile 容器默认资源限制，零值表示不限制
+type ResourceProfile struct {
+	CPUs     float64 `json:"cpus"`
+	MemoryMB int64   `json:"memoryMB"`
+}
+
+// Image 镜像目录中的一项，由管理员维护
+type Image struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Tag         string          `json:"tag"`
+	Digest      string          `json:"digest"`
+	Description string          `json:"description"`
+	Resources   ResourceProfile `json:"resources"`
+}
+
+// Reference 返回用于拉取和创建容器的镜像引用，指定 digest 时优先使用 digest
+func (i *Image) Reference() string {
+	if i.Digest != "" {
+		return i.Name + "@" + i.Digest
+	}
+	tag := i.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return i.Name + ":" + tag
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// 搭建任务状态
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job 异步执行的集群搭建步骤，执行过程中持续保存 chain-proxy 的输出
+type Job struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userID"`
+	Step       string    `json:"step"`
+	Status     string    `json:"status"`
+	Output     string    `json:"output"`
+	StatusCode int       `json:"statusCode,omitempty"` // chain-proxy 返回的状态码
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
@@ -0,0 +1,13 @@
+package models
+
+// Lab 实验（班级），决定学生容器使用的镜像
+type Lab struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ImageID     string `json:"imageID"`
+	// TerminalEnabled 是否允许该实验的学生使用网页终端
+	TerminalEnabled bool `json:"terminalEnabled"`
+	// ExecRules 该实验额外允许或覆盖的 exec 规则，优先于默认策略匹配
+	ExecRules ExecPolicy `json:"execRules,omitempty"`
+}
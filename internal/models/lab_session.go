@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// LabSession 已排期的实验课时段，用于在上课前按人数预热容器池
+type LabSession struct {
+	ID       string    `json:"id"`
+	LabID    string    `json:"labID"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+	PoolSize int       `json:"poolSize"`
+	Prestart bool      `json:"prestart"`
+}
+
+// ActiveAt 判断时段（含提前预热时间 lead）是否覆盖时间点 t
+func (s *LabSession) ActiveAt(t time.Time, lead time.Duration) bool {
+	return !t.Before(s.StartsAt.Add(-lead)) && t.Before(s.EndsAt)
+}
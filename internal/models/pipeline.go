@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 流水线执行状态
+const (
+	PipelineRunning   = "running"
+	PipelineSucceeded = "succeeded"
+	PipelineFailed    = "failed"
+)
+
+// Pipeline 按顺序调用 chain-proxy 接口的一键搭建流程，由管理员以 YAML 或 JSON 定义
+type Pipeline struct {
+	Name        string         `json:"name" yaml:"name"`
+	Description string         `json:"description,omitempty" yaml:"description"`
+	Steps       []PipelineStep `json:"steps" yaml:"steps"`
+}
+
+// PipelineStep 流水线中的一步
+type PipelineStep struct {
+	Name   string `json:"name" yaml:"name"`
+	Method string `json:"method,omitempty" yaml:"method"` // 为空时为 POST
+	Path   string `json:"path" yaml:"path"`
+	Body   string `json:"body,omitempty" yaml:"body"`
+	// Retries 失败后的重试次数，RetryDelay 为每次重试前的等待时间
+	Retries    int           `json:"retries,omitempty" yaml:"retries"`
+	RetryDelay time.Duration `json:"retryDelay,omitempty" yaml:"retryDelay"`
+	// ExpectStatus 期望的状态码，为 0 时接受任意 2xx；ExpectOutput 为输出中必须包含的文本
+	ExpectStatus int    `json:"expectStatus,omitempty" yaml:"expectStatus"`
+	ExpectOutput string `json:"expectOutput,omitempty" yaml:"expectOutput"`
+}
+
+// Validate 检查流水线定义是否完整
+func (p *Pipeline) Validate() error {
+	if p.Name == "" {
+		return errors.New("pipeline name is required")
+	}
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("pipeline %s has no steps", p.Name)
+	}
+	seen := make(map[string]bool)
+	for i, step := range p.Steps {
+		if step.Name == "" || seen[step.Name] {
+			return fmt.Errorf("pipeline %s: step %d has an empty or duplicate name", p.Name, i+1)
+		}
+		seen[step.Name] = true
+		if !strings.HasPrefix(step.Path, "/") {
+			return fmt.Errorf("pipeline %s: step %s has an invalid path %q", p.Name, step.Name, step.Path)
+		}
+		if step.Retries < 0 || step.RetryDelay < 0 {
+			return fmt.Errorf("pipeline %s: step %s has negative retries", p.Name, step.Name)
+		}
+	}
+	return nil
+}
+
+// Check 校验一次调用的结果是否符合步骤的期望
+func (s *PipelineStep) Check(statusCode int, output string) error {
+	if s.ExpectStatus != 0 && statusCode != s.ExpectStatus {
+		return fmt.Errorf("expected status %d, got %d", s.ExpectStatus, statusCode)
+	}
+	if s.ExpectStatus == 0 && (statusCode < 200 || statusCode >= 300) {
+		return fmt.Errorf("unexpected status %d", statusCode)
+	}
+	if s.ExpectOutput != "" && !strings.Contains(output, s.ExpectOutput) {
+		return fmt.Errorf("output does not contain %q", s.ExpectOutput)
+	}
+	return nil
+}
+
+// PipelineRun 一次流水线执行。每步成功后更新 NextStep 作为断点，失败后可从断点继续
+type PipelineRun struct {
+	ID        string               `json:"id"`
+	UserID    string               `json:"userID"`
+	Pipeline  string               `json:"pipeline"`
+	Status    string               `json:"status"`
+	NextStep  int                  `json:"nextStep"` // 下一个待执行步骤的下标
+	Steps     []PipelineStepResult `json:"steps"`
+	Error     string               `json:"error,omitempty"`
+	CreatedAt time.Time            `json:"createdAt"`
+	UpdatedAt time.Time            `json:"updatedAt"`
+}
+
+// PipelineStepResult 步骤的执行结果
+type PipelineStepResult struct {
+	Name       string    `json:"name"`
+	Status     string    `json:"status,omitempty"` // 尚未执行时为空
+	Attempts   int       `json:"attempts,omitempty"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
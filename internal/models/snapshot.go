@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Snapshot 学生容器的还原点，包含提交的镜像和数据卷的 tar 归档
+type Snapshot struct {
+	UserID          string    `json:"userID"`
+	Name            string    `json:"name"`
+	ImageRepository string    `json:"imageRepository"`
+	ImageTag        string    `json:"imageTag"`
+	DockerHost      string    `json:"dockerHost"` // 快照镜像所在的 Docker 主机
+	ArchiveSize     int64     `json:"archiveSize"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
@@ -1,9 +1,26 @@
 package models
 
+// 用户角色
+const (
+	RoleStudent = "student"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
 	ID             string `json:"userID"`
 	Password       string `json:"password"`
+	Role           string `json:"role"`
+	LabID          string `json:"labID"`
 	ContainerID    string `json:"containerID"`
 	Port           string `json:"port"`
+	Network        string `json:"network"`
+	Volume         string `json:"volume"`
+	DockerHost     string `json:"dockerHost"`     // 容器、网络和数据卷所在的 Docker 主机，单主机时为空
+	ContainerState string `json:"containerState"` // 由 Docker 事件同步的容器状态
 	CourseProgress int    `json:"courseProgress"`
 }
+
+// IsAdmin 判断用户是否为管理员（教师/助教）
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
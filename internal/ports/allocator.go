@@ -0,0 +1,73 @@
+package ports
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Allocator 在配置的端口范围内为每个用户分配连续的宿主机端口块，
+// 块内第一个端口对应容器的主端口，其余端口依次对应其他发布端口
+type Allocator struct {
+	mu    sync.Mutex
+	start int
+	end   int
+	block int
+	used  map[int]bool // 已分配端口块的起始端口
+}
+
+// NewAllocator 创建端口分配器，端口范围为 [start, end]，每次分配 block 个连续端口
+func NewAllocator(start, end, block int) (*Allocator, error) {
+	if block < 1 {
+		block = 1
+	}
+	if start <= 0 || end > 65535 || end-start+1 < block {
+		return nil, fmt.Errorf("invalid port range %d-%d for block size %d", start, end, block)
+	}
+	return &Allocator{
+		start: start,
+		end:   end,
+		block: block,
+		used:  make(map[int]bool),
+	}, nil
+}
+
+// Allocate 分配一个空闲端口块，返回起始端口
+func (a *Allocator) Allocate() (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for port := a.start; port+a.block-1 <= a.end; port += a.block {
+		if !a.used[port] {
+			a.used[port] = true
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free ports in range %d-%d", a.start, a.end)
+}
+
+// Reserve 标记已被占用的端口块，用于启动时从用户记录恢复分配状态
+func (a *Allocator) Reserve(port int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if port < a.start || port+a.block-1 > a.end || (port-a.start)%a.block != 0 {
+		return fmt.Errorf("port %d is not a valid block start in range %d-%d", port, a.start, a.end)
+	}
+	if a.used[port] {
+		return fmt.Errorf("port %d is already allocated", port)
+	}
+	a.used[port] = true
+	return nil
+}
+
+// Release 释放端口块
+func (a *Allocator) Release(port int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.used, port)
+}
+
+// BlockSize 返回每个端口块包含的端口数
+func (a *Allocator) BlockSize() int {
+	return a.block
+}
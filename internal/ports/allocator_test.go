@@ -0,0 +1,47 @@
+package ports
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocatorAllocatesUniqueBlocks(t *testing.T) {
+	allocator, err := NewAllocator(20000, 20005, 2)
+	assert.NoError(t, err)
+
+	seen := map[int]bool{}
+	for i := 0; i < 3; i++ {
+		port, err := allocator.Allocate()
+		assert.NoError(t, err)
+		assert.False(t, seen[port])
+		seen[port] = true
+	}
+
+	// 范围内的端口块已用尽
+	_, err = allocator.Allocate()
+	assert.Error(t, err)
+
+	allocator.Release(20002)
+	port, err := allocator.Allocate()
+	assert.NoError(t, err)
+	assert.Equal(t, 20002, port)
+}
+
+func TestAllocatorReserve(t *testing.T) {
+	allocator, err := NewAllocator(20000, 20009, 1)
+	assert.NoError(t, err)
+
+	assert.NoError(t, allocator.Reserve(20000))
+	assert.Error(t, allocator.Reserve(20000))
+	assert.Error(t, allocator.Reserve(30000))
+
+	port, err := allocator.Allocate()
+	assert.NoError(t, err)
+	assert.Equal(t, 20001, port)
+}
+
+func TestNewAllocatorInvalidRange(t *testing.T) {
+	_, err := NewAllocator(20000, 20000, 2)
+	assert.Error(t, err)
+}
@@ -1,12 +1,17 @@
 package server
 
 import (
+	"context"
 	"github.com/cynic-1/blockchain-teaching-system/internal/api"
 	"github.com/cynic-1/blockchain-teaching-system/internal/auth"
 	"github.com/cynic-1/blockchain-teaching-system/internal/config"
 	"github.com/cynic-1/blockchain-teaching-system/internal/database"
 	"github.com/cynic-1/blockchain-teaching-system/internal/docker"
+	"github.com/cynic-1/blockchain-teaching-system/internal/models"
+	"github.com/cynic-1/blockchain-teaching-system/internal/ports"
 	"github.com/gin-gonic/gin"
+	"log"
+	"strconv"
 )
 
 type Server struct {
@@ -14,18 +19,46 @@ type Server struct {
 	config *config.Config
 	db     *database.Database
 	docker *docker.DockerManager
+	ports  *ports.Allocator
 }
 
 func NewServer(config *config.Config) (*Server, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	db, err := database.NewDatabase(config.BadgerDBPath)
 	if err != nil {
 		return nil, err
 	}
 
-	dockerManager, err := docker.NewDockerManager(config.DockerAPIVersion)
+	hosts := make([]docker.HostConfig, 0, len(config.DockerHosts))
+	for _, host := range config.DockerHosts {
+		hosts = append(hosts, docker.HostConfig{
+			Name:         host.Name,
+			Endpoint:     host.Endpoint,
+			Weight:       host.Weight,
+			ProxyAddress: host.ProxyAddress,
+		})
+	}
+	dockerManager, err := docker.NewMultiHostManager(config.DockerAPIVersion, hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	portAllocator, err := newPortAllocator(config, db)
 	if err != nil {
 		return nil, err
 	}
+
+	if err := promoteAdmins(config, db); err != nil {
+		return nil, err
+	}
+
+	if err := config.LoadPipelineDir(); err != nil {
+		return nil, err
+	}
+
 	//
 	//err = auth.InitSecretKey()
 	//if err != nil {
@@ -37,17 +70,81 @@ func NewServer(config *config.Config) (*Server, error) {
 		config: config,
 		db:     db,
 		docker: dockerManager,
+		ports:  portAllocator,
 	}
 
 	server.setupRoutes()
 	return server, nil
 }
 
+// newPortAllocator 创建端口分配器，并根据用户记录恢复已分配的端口
+func newPortAllocator(config *config.Config, db *database.Database) (*ports.Allocator, error) {
+	allocator, err := ports.NewAllocator(config.PortRangeStart, config.PortRangeEnd, len(config.PublishedPorts))
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := db.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.Port == "" {
+			continue
+		}
+		port, err := strconv.Atoi(user.Port)
+		if err == nil {
+			err = allocator.Reserve(port)
+		}
+		if err != nil {
+			log.Printf("Failed to restore port %s of user %s: %v", user.Port, user.ID, err)
+		}
+	}
+	return allocator, nil
+}
+
+// promoteAdmins 将配置中的管理员用户设为管理员角色。注册接口不授予管理员角色，
+// 部署者先注册账号，再将其加入 AdminUsers 并重启服务
+func promoteAdmins(config *config.Config, db *database.Database) error {
+	for _, userID := range config.AdminUsers {
+		user, err := db.GetUser(userID)
+		if err != nil {
+			log.Printf("Admin user %s is not registered: %v", userID, err)
+			continue
+		}
+		if user.Role == models.RoleAdmin {
+			continue
+		}
+		user.Role = models.RoleAdmin
+		if err := db.SaveUser(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Server) setupRoutes() {
 	handler := &api.Handler{
 		DB:     s.db,
 		Docker: s.docker,
+		Config: s.config,
+		Ports:  s.ports,
+		Queue:  docker.NewQueue(s.config.QueueWorkers, s.config.OperationRetention),
 	}
+	handler.Pool = api.NewContainerPool(handler)
+	go handler.Pool.Run(context.Background())
+	handler.Events = api.NewEventWatcher(handler)
+	go handler.Events.Run(context.Background())
+	handler.Stats = api.NewStatsCollector(handler)
+	go handler.Stats.Run(context.Background())
+	handler.Jobs = api.NewJobManager(handler)
+	go handler.Jobs.Run(context.Background())
+	handler.Pipelines = api.NewPipelineRunner(handler)
+	handler.Pipelines.Recover()
+	if s.config.AutoRestartUnhealthy {
+		go handler.MonitorHealth(context.Background())
+	}
+
 	// 公开路由组，不需要 token 验证
 	public := s.router.Group("/api")
 	{
@@ -58,28 +155,85 @@ func (s *Server) setupRoutes() {
 	// 受保护的路由组，需要 token 验证
 	protected := s.router.Group("/api")
 	protected.Use(auth.JWTMiddleware())
+	// 按用户角色限流，创建容器和编译区块链另有每日配额
+	containerLimit := handler.RateLimit(api.RateGroupContainer)
+	execLimit := handler.RateLimit(api.RateGroupExec)
+	setupLimit := handler.RateLimit(api.RateGroupSetup)
 	{
-		protected.POST("/container/create", handler.CreateContainer)
-		protected.POST("/container/start", handler.StartContainer)
-		protected.POST("/container/exec", handler.Exec)
-		protected.POST("/container/stop", handler.StopContainer)
-		protected.POST("/container/remove", handler.RemoveContainer)
+		protected.POST("/container/create", containerLimit, handler.DailyQuota(api.QuotaCreate), handler.CreateContainer)
+		protected.POST("/container/start", containerLimit, handler.StartContainer)
+		protected.POST("/container/exec", execLimit, handler.Exec)
+		protected.POST("/container/stop", containerLimit, handler.StopContainer)
+		protected.POST("/container/remove", containerLimit, handler.RemoveContainer)
+		protected.GET("/container/status", handler.ContainerStatus)
+		protected.GET("/container/logs", handler.ContainerLogs)
+		protected.GET("/container/events", handler.ContainerEvents)
+		protected.GET("/container/stats", handler.ContainerStats)
+		protected.GET("/container/terminal", handler.Terminal)
+		protected.POST("/container/files", containerLimit, handler.UploadFile)
+		protected.GET("/container/files", handler.DownloadFile)
+		protected.GET("/operations/:id", handler.GetOperation)
+		protected.GET("/jobs/:id", handler.GetJob)
+		protected.GET("/jobs/:id/stream", handler.StreamJob)
+		protected.POST("/volume/reset", containerLimit, handler.ResetVolume)
+		protected.DELETE("/account", handler.DeleteAccount)
+
+		// 一键搭建流水线
+		protected.GET("/pipelines", handler.ListPipelines)
+		protected.POST("/pipelines/:name/run", setupLimit, handler.RunPipeline)
+		protected.GET("/pipeline-runs/:id", handler.GetPipelineRun)
+		protected.POST("/pipeline-runs/:id/resume", setupLimit, handler.ResumePipelineRun)
+
+		protected.GET("/snapshots", handler.ListSnapshots)
+		protected.POST("/snapshots", containerLimit, handler.CreateSnapshot)
+		protected.POST("/snapshots/:name/restore", containerLimit, handler.RestoreSnapshot)
+		protected.DELETE("/snapshots/:name", handler.DeleteSnapshot)
+
+		// 集群搭建步骤
+		protected.POST("/create-cluster-factory", setupLimit, handler.CreateClusterFactory)
+		protected.POST("/make-local-addresses", setupLimit, handler.MakeLocalAddresses)
+		protected.POST("/make-validator-keys", setupLimit, handler.MakeValidatorKeysAndStakeQuotas)
+		protected.POST("/write-genesis-files", setupLimit, handler.WriteGenesisFiles)
+		protected.POST("/build-blockchain", setupLimit, handler.DailyQuota(api.QuotaBuildChain), handler.BuildBlockchainBinary)
+		protected.POST("/reset-working-directory", setupLimit, handler.ResetWorkingDirectory)
+		protected.POST("/cluster/create", setupLimit, handler.CreateCluster)
+		protected.POST("/cluster/start", setupLimit, handler.StartCluster)
+		protected.POST("/cluster/stop", setupLimit, handler.StopCluster)
+		protected.GET("/cluster/liveness", setupLimit, handler.ClusterLiveness)
+
+		// 透传到学生容器内 chain-proxy 的接口，受 exec 策略约束
+		protected.Any("/chain/*path", execLimit, handler.ChainProxy)
 
 		// deprecated
 		//protected.GET("/consensus-status", handler.GetConsensusStatus)
 		//protected.GET("/txpool-status", handler.GetTxpoolStatus)
 		//protected.GET("/block", handler.GetBlockAtHeight)
-		//protected.POST("/create-cluster-factory", handler.CreateLocalClusterFactory)
-		//protected.POST("/make-local-addresses", handler.MakeLocalAddresses)
-		//protected.POST("/make-validator-keys", handler.MakeValidatorKeysAndStakeQuotas)
-		//protected.POST("/write-genesis-files", handler.WriteGenesisFiles)
-		//protected.POST("/build-blockchain", handler.BuildBlockchainBinary)
-		//protected.POST("/reset-working-directory", handler.ResetWorkingDirectory)
-		//protected.POST("/cluster/create", handler.CreateCluster)
-		//protected.POST("/cluster/start", handler.StartCluster)
-		//protected.POST("/cluster/stop", handler.StopCluster)
 		// 添加其他需要验证的路由...
 	}
+
+	// 管理员路由组，需要 token 验证且用户为管理员
+	admin := s.router.Group("/api/admin")
+	admin.Use(auth.JWTMiddleware(), handler.RequireAdmin())
+	{
+		admin.GET("/images", handler.ListImages)
+		admin.POST("/images", handler.SaveImage)
+		admin.DELETE("/images/:id", handler.DeleteImage)
+		admin.POST("/images/:id/pull", handler.PullImage)
+
+		admin.GET("/labs", handler.ListLabs)
+		admin.POST("/labs", handler.SaveLab)
+		admin.DELETE("/labs/:id", handler.DeleteLab)
+
+		admin.GET("/sessions", handler.ListLabSessions)
+		admin.POST("/sessions", handler.SaveLabSession)
+		admin.DELETE("/sessions/:id", handler.DeleteLabSession)
+		admin.GET("/pool", handler.PoolStatus)
+		admin.GET("/stats", handler.AdminStats)
+
+		admin.PUT("/users/:id/lab", handler.AssignUserLab)
+		admin.DELETE("/users/:id", handler.AdminDeleteUser)
+		admin.POST("/users/:id/pipelines/:name/run", handler.AdminRunPipeline)
+	}
 }
 
 func (s *Server) Run() error {